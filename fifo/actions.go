@@ -0,0 +1,224 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Types of corporate actions
+const (
+	// Stock split, the value is the number of new shares per old share
+	actionSplit    string = "split"
+	// Reverse split, the value is the number of old shares per new share
+	actionReverse  string = "reverse"
+	// Cash dividend, the value is the amount per share
+	actionDividend string = "dividend"
+)
+
+// Action is a corporate action taking effect at the start of a bar, before
+// any trades on the bar.
+type Action struct {
+	// Bar ID, as in the input data
+	Bar   string
+
+	// Type of the action: 'split', 'reverse' or 'dividend'
+	Type  string
+
+	// Split ratio or dividend per share
+	Value float64
+}
+
+// ReadActions reads corporate actions in the CSV format, 3 columns: bar ID,
+// type and ratio (or amount). The validation mode is the same as for bars.
+func ReadActions(r io.Reader, name string, par Params) ([]Action, Problems, error) {
+	var (
+		all []Action
+	)
+	strict, err := strictMode(par.Validation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, problems, err := readRecords(r, name)
+	if err != nil {
+		return nil, problems, err
+	}
+	if par.Headers && len(records) > 0 {
+		records = records[1:]
+	}
+
+	for _, rec := range records {
+		one, found := rec.action()
+		for i := range found {
+			found[i].File = name
+		}
+		if len(found) > 0 {
+			problems = append(problems, found...)
+			continue
+		}
+		all = append(all, one)
+	}
+
+	if strict && len(problems) > 0 {
+		return nil, problems, problems
+	}
+	return all, problems, nil
+}
+
+// action parses the record into a corporate action.
+func (rec record) action() (Action, Problems) {
+	var (
+		one      Action
+		problems Problems
+	)
+	add := func(col int, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			Line:   rec.Line,
+			Column: col,
+			Msg:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(rec.Fields) != 3 {
+		add(0, "%d columns expected, %d found", 3, len(rec.Fields))
+		return one, problems
+	}
+	one.Bar  = rec.Fields[0]
+	one.Type = strings.ToLower(strings.TrimSpace(rec.Fields[1]))
+
+	switch one.Type {
+	case actionSplit, actionReverse, actionDividend:
+		// Do nothing
+
+	default:
+		add(2, "unknown corporate action '%s'", rec.Fields[1])
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(rec.Fields[2]), 64)
+	switch {
+	case err != nil:
+		add(3, "ratio or amount '%s' is not a number", rec.Fields[2])
+
+	case !(value > 0):
+		add(3, "ratio or amount %v is not positive", value)
+	}
+	one.Value = value
+
+	return one, problems
+}
+
+// ApplyActions puts corporate actions into the bars with the same IDs. Split
+// ratios of several actions on a bar are multiplied, dividends are added up.
+// An action is applied to the first bar with its ID.
+func ApplyActions(bars []Bar, actions []Action) error {
+	var missing []string
+
+	index := make(map[string]int, len(bars))
+	for i := len(bars) - 1; i >= 0; i-- {
+		index[bars[i].ID] = i
+	}
+
+	for _, one := range actions {
+		i, ok := index[one.Bar]
+		if !ok {
+			missing = append(missing, one.Bar)
+			continue
+		}
+
+		switch one.Type {
+		case actionSplit:
+			bars[i].Split = splitRatio(bars[i].Split) * one.Value
+
+		case actionReverse:
+			bars[i].Split = splitRatio(bars[i].Split) / one.Value
+
+		case actionDividend:
+			bars[i].Dividend += one.Value
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("no bars found for corporate actions on %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// readActions reads the corporate actions file.
+func readActions(file string, par Params) ([]Action, Problems, error) {
+	fmt.Printf("Reading corporate actions from file %s ...\n", file)
+
+	csvFile, err := os.Open(file)
+	if err != nil {
+		msg := "Failed to open a corporate actions file!"
+		warning(msg, err)
+		return nil, nil, err
+	}
+	defer csvFile.Close()
+
+	return ReadActions(csvFile, file, par)
+}
+
+// splitRatio returns the number of new shares per old share; 1 if there is
+// no split.
+func splitRatio(ratio float64) float64 {
+	if ratio <= 0 {
+		return 1
+	}
+	return ratio
+}
+
+// corporate applies corporate actions to the positions held at the end of
+// the previous bar. Splits rescale quantities and cost prices of pending lots
+// without changing their basis. Dividends are credited to long lots and
+// debited from short lots.
+// Note: A copy of the previous bar is adjusted, the history is left intact.
+func (this *Asset) corporate(bar Bar, prev Asset) Asset {
+	this.S.Div = 0
+	this.L.Div = 0
+
+	if ratio := splitRatio(bar.Split); ratio != 1 {
+		prev.S.Queue = rescale(prev.S.Queue, ratio)
+		prev.L.Queue = rescale(prev.L.Queue, ratio)
+		prev.S.Qty.E *= ratio
+		prev.L.Qty.E *= ratio
+		prev.S.Losses = rescaleLosses(prev.S.Losses, ratio)
+		prev.L.Losses = rescaleLosses(prev.L.Losses, ratio)
+		prev.Pxs.Cl  /= ratio
+		prev.Pxs.ATR /= ratio
+	}
+
+	// Note: Sign convention. The short stock has a negative quantity, so that
+	// the dividend is paid (negative cash flow).
+	this.S.Div = prev.S.Qty.E * bar.Dividend
+	this.L.Div = prev.L.Qty.E * bar.Dividend
+
+	return prev
+}
+
+// rescale returns a copy of the queue with quantities multiplied and cost
+// prices (as well as fees and slippage per share, best and worst prices and
+// average true ranges) divided by the split ratio.
+func rescale(queue []Pending, ratio float64) []Pending {
+	scaled := make([]Pending, len(queue))
+	for i, one := range queue {
+		one.Qty  *= ratio
+		one.Cost /= ratio
+		one.Fee  /= ratio
+		one.Slip /= ratio
+		one.Peak  /= ratio
+		one.Worst /= ratio
+		one.ATR   /= ratio
+		scaled[i] = one
+	}
+	return scaled
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestApplyActions(t *testing.T) {
+	tests := []struct {
+		name     string
+		actions  []Action
+		ok       bool
+		split    []float64
+		dividend []float64
+	}{
+		{
+			name:     "split",
+			actions:  []Action{{"2020-01-03", actionSplit, 2}},
+			ok:       true,
+			split:    []float64{0, 2, 0},
+			dividend: []float64{0, 0, 0},
+		},
+		{
+			name:     "reverse split",
+			actions:  []Action{{"2020-01-06", actionReverse, 4}},
+			ok:       true,
+			split:    []float64{0, 0, 0.25},
+			dividend: []float64{0, 0, 0},
+		},
+		{
+			name:     "split and reverse split on a bar",
+			actions:  []Action{{"2020-01-03", actionSplit, 3}, {"2020-01-03", actionReverse, 2}},
+			ok:       true,
+			split:    []float64{0, 1.5, 0},
+			dividend: []float64{0, 0, 0},
+		},
+		{
+			name:     "dividends added up",
+			actions:  []Action{{"2020-01-02", actionDividend, 0.5}, {"2020-01-02", actionDividend, 0.25}},
+			ok:       true,
+			split:    []float64{0, 0, 0},
+			dividend: []float64{0.75, 0, 0},
+		},
+		{
+			name:    "no bar",
+			actions: []Action{{"2020-01-04", actionDividend, 0.5}},
+			ok:      false,
+		},
+	}
+	for _, tt := range tests {
+		bars := dated([]string{"2020-01-02", "2020-01-03", "2020-01-06"}, []float64{100, 100, 100}, []float64{1, 1, 1})
+
+		err := ApplyActions(bars, tt.actions)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		for i, one := range bars {
+			if math.Abs(one.Split - tt.split[i]) > 1e-12 || math.Abs(one.Dividend - tt.dividend[i]) > 1e-12 {
+				t.Errorf("%s: bar %s split %v and dividend %v, want %v and %v", tt.name, one.ID, one.Split, one.Dividend, tt.split[i], tt.dividend[i])
+			}
+		}
+	}
+}
+
+func TestCorporate(t *testing.T) {
+	// A short and a long lot of 100 shares at 50 held over the ex-date
+	var prev Asset
+	prev.Pxs     = Prices{Cl: 50}
+	prev.S.Queue = []Pending{{N: 1, Size: 1, Qty: -100, Cost: 50, Basis: -5000, Peak: 48}}
+	prev.L.Queue = []Pending{{N: 1, Size: 1, Qty: 100, Cost: 50, Basis: 5000, Peak: 52}}
+	prev.S.Qty.E = -100
+	prev.L.Qty.E = 100
+
+	tests := []struct {
+		name       string
+		bar        Bar
+		qty, cost  float64 // of the long lot
+		close      float64
+		divS, divL float64
+	}{
+		{"no action", Bar{}, 100, 50, 50, 0, 0},
+		{"split", Bar{Split: 2}, 200, 25, 25, 0, 0},
+		{"reverse split", Bar{Split: 0.5}, 50, 100, 100, 0, 0},
+		{"dividend", Bar{Dividend: 0.4}, 100, 50, 50, -40, 40},
+		{"split and dividend per new share", Bar{Split: 2, Dividend: 0.4}, 200, 25, 25, -80, 80},
+	}
+	for _, tt := range tests {
+		var this Asset
+		adjusted := this.corporate(tt.bar, prev)
+
+		lot := adjusted.L.Queue[0]
+		if math.Abs(lot.Qty - tt.qty) > 1e-9 || math.Abs(lot.Cost - tt.cost) > 1e-9 || adjusted.Pxs.Cl != tt.close {
+			t.Errorf("%s: lot of %v shares at %v, close %v; want %v at %v, %v", tt.name, lot.Qty, lot.Cost, adjusted.Pxs.Cl, tt.qty, tt.cost, tt.close)
+		}
+		// The basis is not changed, the short lot is rescaled the same way
+		if lot.Basis != 5000 || adjusted.S.Queue[0].Basis != -5000 || math.Abs(adjusted.S.Queue[0].Qty + tt.qty) > 1e-9 {
+			t.Errorf("%s: lots changed to %+v and %+v", tt.name, adjusted.S.Queue[0], lot)
+		}
+		if math.Abs(this.S.Div - tt.divS) > 1e-9 || math.Abs(this.L.Div - tt.divL) > 1e-9 {
+			t.Errorf("%s: dividends %v and %v, want %v and %v", tt.name, this.S.Div, this.L.Div, tt.divS, tt.divL)
+		}
+	}
+	// The history is left intact
+	if prev.L.Queue[0].Qty != 100 || prev.L.Qty.E != 100 || prev.Pxs.Cl != 50 {
+		t.Errorf("previous bar changed to %+v", prev.L)
+	}
+}
+
+func TestActionsSimulated(t *testing.T) {
+	// A long lot of 100 shares entered at 100 and sold on the last bar, held
+	// over the ex-date of the action on the second bar
+	tests := []struct {
+		name     string
+		action   Action
+		prices   []float64
+		realized float64
+		dividend float64
+	}{
+		{"split", Action{"2020-01-03", actionSplit, 2}, []float64{100, 50, 55}, 1000, 0},
+		{"reverse split", Action{"2020-01-03", actionReverse, 2}, []float64{100, 200, 190}, -500, 0},
+		{"cash dividend", Action{"2020-01-03", actionDividend, 1.5}, []float64{100, 100, 102}, 200, 150},
+	}
+	for _, tt := range tests {
+		bars := dated([]string{"2020-01-02", "2020-01-03", "2020-01-06"}, tt.prices, []float64{1, 1, 0})
+		if err := ApplyActions(bars, []Action{tt.action}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		res, err := Simulate(context.Background(), bars, Params{Cash: 1e6, Lim: 10000})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var realized, dividend float64
+		for _, one := range res.Assets {
+			realized += one.L.Result.Rzd
+			dividend += one.L.Div
+		}
+		last := res.Assets[len(res.Assets) - 1]
+		if math.Abs(realized - tt.realized) > 1e-6 || math.Abs(dividend - tt.dividend) > 1e-6 {
+			t.Errorf("%s: realized %v and dividends %v, want %v and %v", tt.name, realized, dividend, tt.realized, tt.dividend)
+		}
+		if want := 1e6 + tt.realized + tt.dividend; math.Abs(last.NAV - want) > 1e-6 || math.Abs(last.Cash - want) > 1e-6 {
+			t.Errorf("%s: NAV %v and cash %v, want %v", tt.name, last.NAV, last.Cash, want)
+		}
+	}
+}
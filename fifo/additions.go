@@ -7,63 +7,84 @@
 // given that returns are not reinvested and positions are not rebalanced.
 package fifo
 
-// additions adds newly open positions to the queue. Positions are split up 
-// into elements (size = 1).
+// additions adds newly open positions to the queue. All positions opened on 
+// the same bar make up a single lot.
 // Note: Fees are taken into account, so that cash is not overspent and the 
-// exposure limit be used in full.
-func (this *Asset) additions(prev Asset, q argsFIFO) {
+// exposure per position be used in full.
+func (this *Asset) additions(acct account, q argsFIFO) {
 	var (
-		sh, ln []Pending
+		sh, ln     []Pending
+		lot        float64
+		lotS, lotL float64
 	)
 
-	this.qtyNew(q.Lim, q.Fee)
-	this.cfNew(q.Fee)
-	this.basisNew()
-
-	// Add elements the queue of pending positions.
-	switch {
-	case this.S.Pos.I != 0:
-		sh = make([]Pending, this.S.Pos.I)
-
-		// The net cost price received from selling one element of short position 
-		// (size = 1).
-		price2receive := this.Pxs.Tx - q.Fee
-
-		for i := range sh {
-			// Selling to open a short position. Price received, fee subtracted.
-			sh[i] = Pending{
-				// Note: Sign convention. The short stock has a negative quantity.
-				Qty:   -q.Lim / price2receive,
-				// Note: Sign convention. All costs are positive.
-				Cost:  price2receive,
-				// Note: Sign convention. SHORT ==> positive proceeds, negative basis.
-				Basis: -q.Lim,
-			}
-		}
-
-	case this.L.Pos.I != 0:
-		ln = make([]Pending, this.L.Pos.I)
+	// Exposure per position
+	this.Exposure = 0
+	if this.S.Pos.I != 0 || this.L.Pos.I != 0 {
+		this.Exposure = this.exposure(acct, q)
+	}
+	this.Exposure = this.cashCheck(this.Exposure, acct, q)
+	lot = this.Exposure
 
-		// The net cost price paid for buying one element of long position 
-		// (size = 1).
-		price2pay := this.Pxs.Tx + q.Fee
+	lotS, lotL = this.qtyNew(lot, q)
+	this.cfNew()
+	this.basisNew()
 
-		for j := range ln {
-			// Buying to open a long position. Price paid, fee added.
-			ln[j] = Pending{
-				// Note: Sign convention. The long stock has a positive quantity.
-				Qty:   +q.Lim / price2pay,
-				// Note: Sign convention. All costs are positive.
-				Cost:  price2pay,
-				// Note: Sign convention. LONG ==> negative proceeds, positive basis.
-				Basis: q.Lim,
-			}
-		}
+	// Add a lot to the queue of pending positions.
+	// Note: Both sides may grow on the same bar in the hedged mode.
+	if this.S.Pos.I != 0 {
+		// Selling to open a short position. Price received, fee subtracted.
+		sh = []Pending{{
+			Bar:   this.Bar,
+			N:     this.N,
+			Acquired: this.Date,
+			Size:  this.S.Pos.I,
+			// Note: Sign convention. The short stock has a negative quantity.
+			Qty:   this.S.Qty.I,
+			// The net cost price received from selling one share, fee subtracted.
+			// Note: Sign convention. All costs are positive.
+			Cost:  this.S.PxI - this.S.FeeI,
+			// Note: Sign convention. SHORT ==> positive proceeds, negative basis.
+			Basis: -basis(lotS, this.S.Pos.I, this.S.Qty.I),
+			Fee:   this.S.FeeI,
+			Slip:  this.Pxs.quote(true) - this.S.PxI,
+			Peak:  this.S.PxI,
+			Worst: this.S.PxI,
+			ATR:   this.Pxs.ATR,
+		}}
+	}
 
-	default:
-		// Do nothing
+	if this.L.Pos.I != 0 {
+		// Buying to open a long position. Price paid, fee added.
+		ln = []Pending{{
+			Bar:   this.Bar,
+			N:     this.N,
+			Acquired: this.Date,
+			Size:  this.L.Pos.I,
+			// Note: Sign convention. The long stock has a positive quantity.
+			Qty:   this.L.Qty.I,
+			// The net cost price paid for buying one share, fee added.
+			// Note: Sign convention. All costs are positive.
+			Cost:  this.L.PxI + this.L.FeeI,
+			// Note: Sign convention. LONG ==> negative proceeds, positive basis.
+			Basis: basis(lotL, this.L.Pos.I, this.L.Qty.I),
+			Fee:   this.L.FeeI,
+			Slip:  this.L.PxI - this.Pxs.quote(false),
+			Peak:  this.L.PxI,
+			Worst: this.L.PxI,
+			ATR:   this.Pxs.ATR,
+		}}
 	}
-	this.S.Queue = queueAdd(prev.S.Queue, sh)
-	this.L.Queue = queueAdd(prev.L.Queue, ln)
+	// Note: Exits have been removed from the queues already.
+	this.S.Queue = queueAdd(this.S.Queue, sh)
+	this.L.Queue = queueAdd(this.L.Queue, ln)
 }
 
+// basis returns the basis of a new lot, i.e. the exposure used in full; zero
+// if nothing is traded, e.g. the exposure does not cover minimum fees.
+func basis(lot, size, qty float64) float64 {
+	if qty == 0 {
+		return 0
+	}
+	return lot * size
+}
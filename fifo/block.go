@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxBlock checks the maximum number of bars an exit may be blocked.
+func maxBlock(bars int) (int, error) {
+	if bars < 0 {
+		return 0, fmt.Errorf("maximum number of blocked bars %d is negative", bars)
+	}
+	return bars, nil
+}
+
+// noLossExit blocks the exits which would realize a loss on the lots being
+// relieved, unless the exit has already been blocked for the maximum number
+// of bars. A blocked exit is signalled again on the next bar, as the position
+// is held on.
+// Note: In the netted mode, the entry of a flip-over is blocked along with
+// the exit, so that the sides are never held at the same time.
+func (this *Asset) noLossExit(prev Asset, q argsFIFO) {
+	this.Block  = false
+	this.BlockN = 0
+
+	if !q.NoLoss {
+		return
+	}
+	if q.MaxBlock > 0 && prev.BlockN >= q.MaxBlock {
+		// The exit is forced
+		return
+	}
+
+	// Note: Buying to close short position, selling to close long position.
+	blockS := this.S.Pos.O != 0 && this.loss(prev.S.Queue, this.S.Pos.O, short, q)
+	blockL := this.L.Pos.O != 0 && this.loss(prev.L.Queue, this.L.Pos.O, long, q)
+
+	if blockS {
+		this.S.Pos.O = 0
+		if !q.Hedged {
+			this.L.Pos.I = 0
+		}
+	}
+	if blockL {
+		this.L.Pos.O = 0
+		if !q.Hedged {
+			this.S.Pos.I = 0
+		}
+	}
+
+	if blockS || blockL {
+		this.Block  = true
+		this.BlockN = prev.BlockN + 1
+		this.posEnd(prev)
+	}
+}
+
+// loss tells whether closing n positions of the queue on the bar would
+// realize a loss, fees and slippage accounted.
+func (this *Asset) loss(queue []Pending, n float64, side string, q argsFIFO) bool {
+	var (
+		qty, rzd float64
+	)
+	lots, _ := q.Relief.Relieve(queue, n)
+	for _, one := range lots {
+		qty += one.Qty
+	}
+
+	px   := fill(q.Slip, math.Abs(qty), this.Pxs, side == long)
+	fee  := q.Fees.Fee(math.Abs(qty), px, side == long)
+	slip := math.Abs(px - this.Pxs.quote(side == long))
+	for _, one := range this.closures(lots, side, px, fee, slip) {
+		rzd += one.Rzd
+	}
+	return rzd < 0
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policies on entries which would overdraw cash
+const (
+	// Entries are made anyway and flagged
+	cashAllow  string = "allow"
+	// All entries on the bar are rejected
+	cashReject string = "reject"
+	// The exposure per position is scaled down to the cash available
+	cashScale  string = "scale"
+)
+
+// Flags of the cash check of entries on a bar
+const (
+	flagOverdrawn string = "overdrawn"
+	flagRejected  string = "rejected"
+	flagScaled    string = "scaled"
+)
+
+// cashPolicy returns the policy on entries overdrawing cash by its config
+// name: 'allow' (default), 'reject' or 'scale'.
+func cashPolicy(name string) (string, error) {
+	switch policy := strings.ToLower(strings.TrimSpace(name)); policy {
+	case "":
+		return cashAllow, nil
+
+	case cashAllow, cashReject, cashScale:
+		return policy, nil
+
+	default:
+		return "", fmt.Errorf("unknown cash policy '%s'", name)
+	}
+}
+
+// balance for the cash balance at the end of the bar: the starting cash
+// changed by net cash flows of entries and exits and by dividends.
+func (this *Asset) balance(prev Asset, cashbase float64) {
+	if this.N == 0 {
+		prev.Cash = cashbase
+	}
+	this.Cash = prev.Cash +
+		this.S.NetCF.I + this.S.NetCF.O + this.S.Div +
+		this.L.NetCF.I + this.L.NetCF.O + this.L.Div -
+		this.Borrow - this.Interest
+}
+
+// free returns the cash free for new entries at the end of the bar.
+// Note: Short sales are fully collateralized: the proceeds are held and an
+// equal amount of cash is set aside as margin.
+func (this Asset) free() float64 {
+	// Note: Sign convention. SHORT ==> negative basis.
+	return this.Cash + 2 * this.S.Basis.E
+}
+
+// released returns the cash freed up on the bar before new entries: by exits
+// and by dividends.
+func (this Asset) released() float64 {
+	// Note: Closing short positions releases the margin and the proceeds held,
+	// i.e. twice the basis, less the price paid.
+	return this.L.NetCF.O + this.S.NetCF.O + 2 * this.S.Basis.O +
+		this.S.Div + this.L.Div
+}
+
+// cashCheck checks that new entries of the exposure per position do not
+// overdraw the cash available and applies the cash policy. It returns the
+// exposure per position to be used.
+// Note: Rejected entries are dropped from the position, so that they are
+// signalled again on the next bar.
+func (this *Asset) cashCheck(lot float64, acct account, q argsFIFO) float64 {
+	this.CashFlag = ""
+
+	size := this.S.Pos.I + this.L.Pos.I
+	if size == 0 {
+		return lot
+	}
+	available := acct.Free + this.released()
+	if lot * size <= available {
+		return lot
+	}
+
+	switch {
+	case q.CashPolicy == cashAllow:
+		this.CashFlag = flagOverdrawn
+		return lot
+
+	case q.CashPolicy == cashScale && available > 0:
+		this.CashFlag = flagScaled
+		return available / size
+
+	default:
+		this.CashFlag = flagRejected
+
+		this.S.Pos.E = roundSize(this.S.Pos.E - this.S.Pos.I)
+		this.L.Pos.E = roundSize(this.L.Pos.E - this.L.Pos.I)
+		this.S.Pos.I  = 0
+		this.L.Pos.I  = 0
+		return 0
+	}
+}
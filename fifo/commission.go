@@ -0,0 +1,308 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CommissionModel computes broker's commissions and other fees per order.
+type CommissionModel interface {
+	// Fee returns the fee per share for an order of qty shares (positive)
+	// at the price; sell is true for sales, i.e. long exits and short entries.
+	// Fees charged per order, such as minimums, are spread over the shares.
+	Fee(qty, price float64, sell bool) float64
+}
+
+// Commission holds the settings of a commission model.
+type Commission struct {
+	// Model: per_share (default), percent, tiered or ibkr
+	Model    string  `yaml:"model"`
+
+	// Commission per share, or a fraction of trade value for the percent model
+	Rate     float64 `yaml:"rate"`
+
+	// Minimum commission per order
+	Min      float64 `yaml:"min"`
+
+	// Maximum commission per order as a fraction of trade value
+	Max      float64 `yaml:"max"`
+
+	// Rates per share by the number of shares in an order (tiered model)
+	Tiers    []Tier  `yaml:"tiers"`
+
+	// Exchange fee per share sold
+	Exchange float64 `yaml:"exchange"`
+
+	// Regulatory fees on sells: a fraction of the value sold (e.g. SEC fee),
+	// a fee per share sold (e.g. FINRA TAF) and its maximum per order
+	SEC      float64 `yaml:"sec"`
+	TAF      float64 `yaml:"taf"`
+	TAFMax   float64 `yaml:"taf_max"`
+}
+
+// Tier is a band of the tiered commission model.
+type Tier struct {
+	// The number of shares in an order up to which the rate applies;
+	// 0 for no upper bound
+	UpTo float64 `yaml:"up_to"`
+
+	// Commission per share
+	Rate float64 `yaml:"rate"`
+}
+
+// Defaults of the IBKR-style model: commission per share, minimum per order
+// and maximum per order as a fraction of trade value
+const (
+	ibkrRate float64 = 0.005
+	ibkrMin  float64 = 1.00
+	ibkrMax  float64 = 0.01
+)
+
+// NewCommission returns the commission model set in the config. If no model
+// is set, the flat commission per share (the 'commission' parameter) applies.
+func NewCommission(c Commission, fee float64) (CommissionModel, error) {
+	var model CommissionModel
+
+	switch strings.ToLower(strings.TrimSpace(c.Model)) {
+	case "":
+		model = perShare{Rate: fee}
+
+	case "per_share":
+		model = perShare{Rate: c.Rate, Min: c.Min, Max: c.Max}
+
+	case "percent":
+		model = percent{Rate: c.Rate, Min: c.Min, Max: c.Max}
+
+	case "tiered":
+		if len(c.Tiers) == 0 {
+			return nil, fmt.Errorf("no tiers set for the tiered commission model")
+		}
+		if err := checkTiers(c.Tiers); err != nil {
+			return nil, err
+		}
+		tiers := append([]Tier(nil), c.Tiers...)
+		model = tiered{Tiers: tiers, Min: c.Min, Max: c.Max}
+
+	case "ibkr":
+		ibkr := perShare{Rate: c.Rate, Min: c.Min, Max: c.Max}
+		if ibkr.Rate == 0 {
+			ibkr.Rate = ibkrRate
+		}
+		if ibkr.Min == 0 {
+			ibkr.Min = ibkrMin
+		}
+		if ibkr.Max == 0 {
+			ibkr.Max = ibkrMax
+		}
+		model = ibkr
+
+	default:
+		return nil, fmt.Errorf("unknown commission model '%s'", c.Model)
+	}
+
+	if c.Exchange != 0 || c.SEC != 0 || c.TAF != 0 {
+		model = sellFees{
+			base:     model,
+			exchange: c.Exchange,
+			sec:      c.SEC,
+			taf:      c.TAF,
+			tafMax:   c.TAFMax,
+		}
+	}
+	return model, nil
+}
+
+// floor returns the lowest commission per share which may be charged on a
+// sale, so that a trade price must be above it.
+func (this Commission) floor(fee float64) float64 {
+	switch strings.ToLower(strings.TrimSpace(this.Model)) {
+	case "":
+		return fee
+
+	case "per_share":
+		return this.Rate
+
+	case "ibkr":
+		if this.Rate == 0 {
+			return ibkrRate
+		}
+		return this.Rate
+
+	default:
+		return 0
+	}
+}
+
+// perShare charges a commission per share, with optional minimum and
+// maximum per order.
+type perShare struct {
+	Rate float64
+	Min  float64
+	Max  float64
+}
+
+func (this perShare) Fee(qty, price float64, sell bool) float64 {
+	if this.Min == 0 && this.Max == 0 {
+		// Note: The flat rate is returned as is, so that no rounding errors
+		// are introduced.
+		return this.Rate
+	}
+	return perOrder(this.Rate * qty, qty, price, this.Min, this.Max)
+}
+
+// percent charges a fraction of trade value, with optional minimum and
+// maximum per order.
+type percent struct {
+	Rate float64
+	Min  float64
+	Max  float64
+}
+
+func (this percent) Fee(qty, price float64, sell bool) float64 {
+	return perOrder(this.Rate * qty * price, qty, price, this.Min, this.Max)
+}
+
+// checkTiers checks that the tiers are listed in the ascending order of their
+// upper bounds, the open-ended tier (if any) being the last one.
+func checkTiers(tiers []Tier) error {
+	for i, one := range tiers {
+		switch {
+		case one.UpTo < 0:
+			return fmt.Errorf("tier %d: upper bound %v is negative", i + 1, one.UpTo)
+
+		case one.UpTo == 0 && i < len(tiers) - 1:
+			return fmt.Errorf("tier %d: only the last tier may have no upper bound", i + 1)
+
+		case i > 0 && one.UpTo != 0 && one.UpTo <= tiers[i - 1].UpTo:
+			return fmt.Errorf("tier %d: upper bound %v does not exceed the one of the previous tier", i + 1, one.UpTo)
+		}
+	}
+	return nil
+}
+
+// tiered charges commissions per share at marginal rates by the number of
+// shares in an order, with optional minimum and maximum per order.
+type tiered struct {
+	Tiers []Tier
+	Min   float64
+	Max   float64
+}
+
+func (this tiered) Fee(qty, price float64, sell bool) float64 {
+	var (
+		total, from float64
+	)
+	for _, one := range this.Tiers {
+		upTo := one.UpTo
+		if upTo == 0 || upTo > qty {
+			upTo = qty
+		}
+		if upTo > from {
+			total += (upTo - from) * one.Rate
+			from   = upTo
+		}
+		if from >= qty {
+			break
+		}
+	}
+	// Note: Shares beyond the last bound are charged at the last rate.
+	if from < qty {
+		total += (qty - from) * this.Tiers[len(this.Tiers)-1].Rate
+	}
+	return perOrder(total, qty, price, this.Min, this.Max)
+}
+
+// sellFees adds exchange and regulatory fees on sells to a commission model.
+type sellFees struct {
+	base     CommissionModel
+	exchange float64
+	sec      float64
+	taf      float64
+	tafMax   float64
+}
+
+func (this sellFees) Fee(qty, price float64, sell bool) float64 {
+	fee := this.base.Fee(qty, price, sell)
+	if !sell || qty <= 0 {
+		return fee
+	}
+
+	taf := this.taf * qty
+	if this.tafMax > 0 {
+		taf = math.Min(taf, this.tafMax)
+	}
+	return fee + this.exchange + this.sec * price + taf / qty
+}
+
+// perOrder applies the minimum and the maximum (a fraction of trade value)
+// to the total commission of an order and returns the commission per share.
+func perOrder(total, qty, price, min, max float64) float64 {
+	if qty <= 0 {
+		return 0
+	}
+	if max > 0 {
+		total = math.Min(total, max * qty * price)
+	}
+	total = math.Max(total, min)
+	return total / qty
+}
+
+// quantity returns the number of shares of an order, so that the net amount
+// paid for a buy (fill price and fees) or received from a sale (fill price 
+// less fees) equals the exposure.
+// Note: If the exposure does not cover the fees of a buy, nothing is bought.
+func quantity(model CommissionModel, slip SlippageModel, exposure float64, pxs Prices, sell bool) float64 {
+	if exposure <= 0 {
+		return 0
+	}
+	_, impact := slip.(sqrtImpact)
+	if flat, ok := model.(perShare); ok && flat.Min == 0 && flat.Max == 0 && !impact {
+		// Closed form for a flat commission per share and a fill price not 
+		// depending on the quantity
+		price := fill(slip, 0, pxs, sell)
+		if sell {
+			return exposure / (price - flat.Rate)
+		}
+		return exposure / (price + flat.Rate)
+	}
+
+	// The net amount of an order of qty shares
+	net := func(qty float64) float64 {
+		price := fill(slip, qty, pxs, sell)
+		fee   := model.Fee(qty, price, sell)
+		if sell {
+			return qty * (price - fee)
+		}
+		return qty * (price + fee)
+	}
+
+	// Bisection: the highest quantity of a buy not overspending the exposure,
+	// or the lowest quantity of a sale yielding the exposure.
+	lo, hi := 0.0, exposure / pxs.quote(sell)
+	if sell {
+		lo = hi
+		for i := 0; i < 64 && net(hi) < exposure; i++ {
+			hi *= 2
+		}
+	}
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if net(mid) < exposure || (!sell && net(mid) == exposure) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	if sell {
+		return hi
+	}
+	return lo
+}
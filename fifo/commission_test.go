@@ -0,0 +1,53 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewCommissionTiers(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers []Tier
+		ok    bool
+	}{
+		{"ascending", []Tier{{300, 0.0035}, {3000, 0.002}, {0, 0.0015}}, true},
+		{"bounded last", []Tier{{300, 0.0035}, {3000, 0.002}}, true},
+		{"single open-ended", []Tier{{0, 0.002}}, true},
+		{"no tiers", nil, false},
+		{"descending", []Tier{{3000, 0.002}, {300, 0.0035}}, false},
+		{"repeated bound", []Tier{{300, 0.0035}, {300, 0.002}}, false},
+		{"two open-ended", []Tier{{0, 0.0035}, {0, 0.002}}, false},
+		{"open-ended first", []Tier{{0, 0.002}, {300, 0.0035}}, false},
+		{"negative bound", []Tier{{-300, 0.0035}}, false},
+	}
+	for _, tt := range tests {
+		_, err := NewCommission(Commission{Model: "tiered", Tiers: tt.tiers}, 0)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestTieredFee(t *testing.T) {
+	model := tiered{Tiers: []Tier{{300, 0.0035}, {3000, 0.002}, {0, 0.0015}}}
+
+	tests := []struct {
+		qty  float64
+		want float64 // total per order
+	}{
+		{100, 100 * 0.0035},
+		{300, 300 * 0.0035},
+		{1000, 300 * 0.0035 + 700 * 0.002},
+		{5000, 300 * 0.0035 + 2700 * 0.002 + 2000 * 0.0015},
+	}
+	for _, tt := range tests {
+		if got := model.Fee(tt.qty, 10, false) * tt.qty; math.Abs(got - tt.want) > 1e-9 {
+			t.Errorf("Fee(%v) = %v per order, want %v", tt.qty, got, tt.want)
+		}
+	}
+}
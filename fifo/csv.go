@@ -15,73 +15,326 @@ import (
 	"strings"
 )
 
-var (
-	attributes string = `Bar
-ClosePx
-TradePx
-SHORT
-LONG
-Entry.S
-Exit.S
-Entry.L
-Exit.L
-Quantity.S
-Quantity.L
-Basis.S
-Basis.L
-Realized.S
-Realized.L
-Assets`
-)
+// optional holds the flags of the optional columns of the results, i.e. of
+// the features producing them.
+type optional struct {
+	signals    bool
+	dividends  bool
+	commission bool
+	slippage   bool
+	spread     bool
+	exposure   bool
+	blocked    bool
+	exits      bool
+	residual   bool
+	borrow     bool
+	interest   bool
+}
+
+// optional tells which optional columns are written to the results by the
+// features set. Dividends are reported if corporate actions are given.
+// Note: The parameters are checked before the calculation.
+func (par Params) optional(actions bool) optional {
+	reinvest, _ := reinvestMode(par.Reinvest)
+	stops, _ := newOverlay(par.Stops)
+	slip := strings.ToLower(strings.TrimSpace(par.Slippage.Model))
+
+	return optional{
+		signals:    par.Delay > 0,
+		dividends:  actions,
+		commission: len(strings.TrimSpace(par.Commission.Model)) > 0,
+		slippage:   len(slip) > 0 && slip != "none",
+		spread:     par.Columns.Bid > 0 && par.Columns.Ask > 0,
+		exposure:   reinvest != reinvestNo || len(strings.TrimSpace(par.Sizing.Model)) > 0,
+		blocked:    par.NoLoss,
+		exits:      stops.on() || par.RoundShares,
+		residual:   par.RoundShares,
+		borrow:     par.Financing.Borrow > 0 || par.Columns.Borrow > 0,
+		interest:   par.Financing.Margin > 0,
+	}
+}
+
+// column is a column of the results: the title and the value on a bar.
+type column struct {
+	title string
+	value func(one Asset) string
+}
+
+// amount makes the value of a numeric column.
+func amount(f func(one Asset) float64) func(one Asset) string {
+	return func(one Asset) string {
+		return fmt.Sprintf("%f", f(one))
+	}
+}
 
-// writeCSVbasic exports results of calculations in the CSV format
-func writeCSVbasic(allRecords []Asset, outFile string) {
+// columns returns the columns of the results: the basic ones and the 
+// optional ones of the features set.
+func (opt optional) columns() []column {
+	cols := []column{
+		{"Bar", func(one Asset) string { return one.Bar }},
+	}
+	if opt.signals {
+		cols = append(cols, column{"SignalBar", func(one Asset) string { return one.SignalBar }})
+	}
+	cols = append(cols,
+		column{"ClosePx", amount(func(one Asset) float64 { return one.Pxs.Cl })},
+		column{"TradePx", amount(func(one Asset) float64 { return one.Pxs.Tx })},
+		column{"SHORT", func(one Asset) string { return formatSize(-one.S.Pos.E) }},
+		column{"LONG", func(one Asset) string { return formatSize(+one.L.Pos.E) }},
+		column{"Entry.S", func(one Asset) string { return formatSize(-one.S.Pos.I) }},
+		column{"Exit.S", func(one Asset) string { return formatSize(+one.S.Pos.O) }},
+		column{"Entry.L", func(one Asset) string { return formatSize(+one.L.Pos.I) }},
+		column{"Exit.L", func(one Asset) string { return formatSize(-one.L.Pos.O) }},
+		column{"Quantity.S", amount(func(one Asset) float64 { return one.S.Qty.E })},
+		column{"Quantity.L", amount(func(one Asset) float64 { return one.L.Qty.E })},
+		column{"Basis.S", amount(func(one Asset) float64 { return one.S.Basis.E })},
+		column{"Basis.L", amount(func(one Asset) float64 { return one.L.Basis.E })},
+		column{"Realized.S", amount(func(one Asset) float64 { return one.S.Result.Rzd })},
+		column{"Realized.L", amount(func(one Asset) float64 { return one.L.Result.Rzd })},
+	)
+	if opt.dividends {
+		cols = append(cols,
+			column{"Dividend.S", amount(func(one Asset) float64 { return one.S.Div })},
+			column{"Dividend.L", amount(func(one Asset) float64 { return one.L.Div })},
+		)
+	}
+	if opt.commission {
+		cols = append(cols,
+			column{"Commission.S", amount(func(one Asset) float64 { return one.S.Fees })},
+			column{"Commission.L", amount(func(one Asset) float64 { return one.L.Fees })},
+		)
+	}
+	if opt.slippage {
+		cols = append(cols,
+			column{"Slippage.S", amount(func(one Asset) float64 { return one.S.Slip })},
+			column{"Slippage.L", amount(func(one Asset) float64 { return one.L.Slip })},
+		)
+	}
+	if opt.spread {
+		cols = append(cols,
+			column{"Spread.S", amount(func(one Asset) float64 { return one.S.Spread })},
+			column{"Spread.L", amount(func(one Asset) float64 { return one.L.Spread })},
+		)
+	}
+	cols = append(cols, column{"Assets", amount(func(one Asset) float64 { return one.NAV })})
+	if opt.exposure {
+		cols = append(cols, column{"Exposure", amount(func(one Asset) float64 { return one.Exposure })})
+	}
+	// Note: The cash is checked by any cash policy, allow (default) included.
+	cols = append(cols,
+		column{"Cash", amount(func(one Asset) float64 { return one.Cash })},
+		column{"CashCheck", func(one Asset) string { return one.CashFlag }},
+	)
+	if opt.blocked {
+		cols = append(cols, column{"Blocked", func(one Asset) string { return strconv.Itoa(one.BlockN) }})
+	}
+	if opt.exits {
+		cols = append(cols,
+			column{"ExitBy.S", func(one Asset) string { return one.S.exitBy() }},
+			column{"ExitBy.L", func(one Asset) string { return one.L.exitBy() }},
+		)
+	}
+	if opt.residual {
+		cols = append(cols, column{"Residual", amount(func(one Asset) float64 { return one.Residual })})
+	}
+	if opt.borrow {
+		cols = append(cols, column{"Borrow", amount(func(one Asset) float64 { return one.Borrow })})
+	}
+	if opt.interest {
+		cols = append(cols, column{"Interest", amount(func(one Asset) float64 { return one.Interest })})
+	}
+	return cols
+}
+
+// writeCSVbasic exports results of calculations in the CSV format. Optional
+// columns are written only if their features are set.
+func writeCSVbasic(allRecords []Asset, opt optional, outFile string) {
 	var (
-		csvNewTName string = outFile
-		err1 error
 		field []string
 	)
 
-	csvNewFile, err := os.OpenFile(csvNewTName, os.O_RDWR, 0666)
+	csvNewFile, err := createCSV(outFile)
 	if err != nil {
-		fmt.Println("Creating output file:", csvNewTName)
-		csvNewFile, err1 = os.Create(csvNewTName)
-		if err1 != nil {
-			fmt.Println("Output file creating error:", err1)
-			return
-		}
+		fmt.Println("Output file creating error:", err)
+		return
 	}
 	defer csvNewFile.Close()
 
 	writer := csv.NewWriter(csvNewFile)
 
-	headers := strings.Split(attributes, "\n")
+	cols := opt.columns()
+	headers := make([]string, len(cols))
+	for i, col := range cols {
+		headers[i] = col.title
+	}
 	writer.Write(headers)
-	// fmt.Println("Headers:", len(headers))
 
 	for _, one := range allRecords {
-		field = make([]string, len(headers))
-
-		field[0] = one.Bar
-		// Convert response to a single string.
-		field[1] = fmt.Sprintf("%f", one.Pxs.Cl)
-		field[2] = fmt.Sprintf("%f", one.Pxs.Tx)
-		field[3] = strconv.Itoa(-one.S.Pos.E)
-		field[4] = strconv.Itoa(+one.L.Pos.E)
-		field[5] = strconv.Itoa(-one.S.Pos.I)
-		field[6] = strconv.Itoa(+one.S.Pos.O)
-		field[7] = strconv.Itoa(+one.L.Pos.I)
-		field[8] = strconv.Itoa(-one.L.Pos.O)
-		field[9] = fmt.Sprintf("%f", one.S.Qty.E)
-		field[10] = fmt.Sprintf("%f", one.L.Qty.E)
-		field[11] = fmt.Sprintf("%f", one.S.Basis.E)
-		field[12] = fmt.Sprintf("%f", one.L.Basis.E)
-		field[13] = fmt.Sprintf("%f", one.S.Result.Rzd)
-		field[14] = fmt.Sprintf("%f", one.L.Result.Rzd)
-		field[15] = fmt.Sprintf("%f", one.NAV)
-
+		field = make([]string, len(cols))
+		for i, col := range cols {
+			field[i] = col.value(one)
+		}
 		writer.Write(field)
 	}
 	writer.Flush()
 	return
 }
+
+// tagged joins the values of the instruments having the bar, tagged by their
+// symbols, e.g. 'AAA:rejected'.
+func (book Book) tagged(symbols []string, value func(symbol string, one Asset) []string) string {
+	var all []string
+	for k, one := range book.Assets {
+		if book.Traded[k] {
+			all = append(all, value(symbols[k], one)...)
+		}
+	}
+	return strings.Join(all, " ")
+}
+
+// writeCSVportfolio exports results of a portfolio in the CSV format: the 
+// combined results (with the delayed signals, the outcomes of cash checks, 
+// blocked exits and kinds of exits by symbols), followed by the net position 
+// and the contribution (the cumulative return) of every instrument. Optional
+// columns are written only if their features are set.
+func writeCSVportfolio(res Portfolio, opt optional, outFile string) {
+	var (
+		field []string
+	)
+
+	csvNewFile, err := createCSV(outFile)
+	if err != nil {
+		fmt.Println("Output file creating error:", err)
+		return
+	}
+	defer csvNewFile.Close()
+
+	writer := csv.NewWriter(csvNewFile)
+
+	// Combined results of a book
+	type combined struct {
+		title string
+		value func(book Book) string
+	}
+	total := func(f func(all Asset) float64) func(book Book) string {
+		return func(book Book) string {
+			return fmt.Sprintf("%f", f(book.Total))
+		}
+	}
+
+	cols := []combined{
+		{"Bar", func(book Book) string { return book.Bar }},
+	}
+	if opt.signals {
+		cols = append(cols, combined{"SignalBar", func(book Book) string {
+			return book.tagged(res.Symbols, func(symbol string, one Asset) []string {
+				if one.SignalBar == one.Bar {
+					return nil
+				}
+				return []string{symbol + ":" + one.SignalBar}
+			})
+		}})
+	}
+	cols = append(cols,
+		combined{"Assets", total(func(all Asset) float64 { return all.NAV })},
+		combined{"Cash", total(func(all Asset) float64 { return all.Cash })},
+		combined{"CashCheck", func(book Book) string {
+			return book.tagged(res.Symbols, func(symbol string, one Asset) []string {
+				if len(one.CashFlag) == 0 {
+					return nil
+				}
+				return []string{symbol + ":" + one.CashFlag}
+			})
+		}},
+	)
+	if opt.blocked {
+		cols = append(cols, combined{"Blocked", func(book Book) string {
+			return book.tagged(res.Symbols, func(symbol string, one Asset) []string {
+				if !one.Block {
+					return nil
+				}
+				return []string{symbol + ":" + strconv.Itoa(one.BlockN)}
+			})
+		}})
+	}
+	if opt.exits {
+		cols = append(cols, combined{"ExitBy", func(book Book) string {
+			return book.tagged(res.Symbols, func(symbol string, one Asset) []string {
+				var exits []string
+				if by := one.S.exitBy(); len(by) > 0 {
+					exits = append(exits, symbol + ".S:" + by)
+				}
+				if by := one.L.exitBy(); len(by) > 0 {
+					exits = append(exits, symbol + ".L:" + by)
+				}
+				return exits
+			})
+		}})
+	}
+	cols = append(cols,
+		combined{"Drawdown", total(func(all Asset) float64 { return all.Drawdown })},
+		combined{"MaxDrawdown", total(func(all Asset) float64 { return all.WDD })},
+		combined{"Commission", total(func(all Asset) float64 { return all.S.Fees + all.L.Fees })},
+	)
+	if opt.slippage {
+		cols = append(cols, combined{"Slippage", total(func(all Asset) float64 { return all.S.Slip + all.L.Slip })})
+	}
+	if opt.spread {
+		cols = append(cols, combined{"Spread", total(func(all Asset) float64 { return all.S.Spread + all.L.Spread })})
+	}
+	if opt.residual {
+		cols = append(cols, combined{"Residual", total(func(all Asset) float64 { return all.Residual })})
+	}
+	if opt.borrow {
+		cols = append(cols, combined{"Borrow", total(func(all Asset) float64 { return all.Borrow })})
+	}
+	if opt.interest {
+		cols = append(cols, combined{"Interest", total(func(all Asset) float64 { return all.Interest })})
+	}
+
+	headers := make([]string, 0, len(cols) + 2 * len(res.Symbols))
+	for _, col := range cols {
+		headers = append(headers, col.title)
+	}
+	for _, symbol := range res.Symbols {
+		headers = append(headers, symbol + ".Position")
+	}
+	for _, symbol := range res.Symbols {
+		headers = append(headers, symbol + ".Contribution")
+	}
+	writer.Write(headers)
+
+	for _, book := range res.Books {
+		field = make([]string, 0, len(headers))
+		for _, col := range cols {
+			field = append(field, col.value(book))
+		}
+		for _, one := range book.Assets {
+			field = append(field, formatSize(one.L.Pos.E - one.S.Pos.E))
+		}
+		for _, one := range book.Assets {
+			field = append(field, fmt.Sprintf("%f", one.CumReturn))
+		}
+
+		writer.Write(field)
+	}
+	writer.Flush()
+}
+
+// formatSize formats a position size with as many decimals as needed, i.e. 
+// none for integer sizes. Zero is never signed.
+func formatSize(size float64) string {
+	if size == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(size, 'f', -1, 64)
+}
+
+// createCSV creates an output file. An existing file is truncated, so that 
+// no records of earlier runs are left behind.
+func createCSV(outFile string) (*os.File, error) {
+	if _, err := os.Stat(outFile); err != nil {
+		fmt.Println("Creating output file:", outFile)
+	}
+	return os.Create(outFile)
+}
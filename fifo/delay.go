@@ -0,0 +1,50 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+)
+
+// execDelay checks the number of bars between a signal and its execution.
+func execDelay(bars int) (int, error) {
+	if bars < 0 {
+		return 0, fmt.Errorf("execution delay %d is negative", bars)
+	}
+	return bars, nil
+}
+
+// delayed returns a copy of the bars with the signals executed n bars later:
+// every bar carries the signal of the n-th bar before it, and no positions
+// are held on the first n bars. Delayed signals are filled at the open of the
+// bar if it is known, otherwise at the trade price.
+// Note: The signals of the last n bars are never executed.
+func delayed(bars []Bar, n int) []Bar {
+	all := make([]Bar, len(bars))
+	copy(all, bars)
+
+	for i := range all {
+		all[i].SignalID = all[i].ID
+		if n == 0 {
+			continue
+		}
+
+		all[i].Position, all[i].Short, all[i].Long = 0, 0, 0
+		all[i].SignalID = ""
+		if i >= n {
+			all[i].Position = bars[i-n].Position
+			all[i].Short    = bars[i-n].Short
+			all[i].Long     = bars[i-n].Long
+			all[i].SignalID = bars[i-n].ID
+		}
+		if all[i].Open > 0 {
+			all[i].Trade = all[i].Open
+		}
+	}
+	return all
+}
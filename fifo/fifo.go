@@ -7,10 +7,25 @@
 // given that returns are not reinvested and positions are not rebalanced.
 package fifo
 
+import (
+	"context"
+	"time"
+)
+
 // Asset for the results of simulated trading
 type Asset struct {
 	// Bar ID, e.g. date/time stamp, in any convenient format, not unique values are allowed
 	Bar       string
+
+	// ID of the bar whose signal is executed on the bar; empty if no signal
+	// is executed yet due to the execution delay
+	SignalBar string
+
+	// The date of the bar; zero if no date layout is set
+	Date      time.Time
+
+	// Bar number (zero-based)
+	N         int
 	
 	// Underlying asset's prices
 	Pxs       Prices
@@ -21,12 +36,39 @@ type Asset struct {
 	// The Long side
 	L         Position
 	
+	// Exposure per position of new entries on the bar
+	Exposure  float64
+
+	// Exposure left unspent by rounding the quantities of new entries down to
+	// whole lots of shares
+	Residual  float64
+
+	// Borrow fees of the short stock and interest on negative cash accrued on
+	// the bar
+	Borrow    float64
+	Interest  float64
+
 	// Cumulative return
 	CumReturn float64
 
+	// Close-to-close returns of the bars in the volatility window, the bar
+	// included; tracked for volatility sizing only
+	Returns   []float64
+
 	// A flag indicating that an exit trade is blocked due to a possible loss
 	Block     bool
 
+	// The number of bars in a row the exit has been blocked, the current bar
+	// included; 0 if not blocked
+	BlockN    int
+
+	// Cash balance
+	Cash      float64
+
+	// The outcome of the cash check of new entries on the bar: 'overdrawn', 
+	// 'rejected', 'scaled' or empty if the entries were covered by cash
+	CashFlag  string
+
 	// Net Asset Value
 	NAV       float64
 
@@ -61,10 +103,58 @@ type Position struct {
 	
 	// FIFO queue
 	Queue  []Pending
+
+	// Lots closed on the bar
+	Closed []Closed
 	
 	// Net cash flow, net proceeds
 	NetCF  Tally
 
+	// Dividends received (positive) or paid (negative)
+	Div    float64
+
+	// Fill prices of the entry order and of the exit order on the bar, 
+	// slippage accounted
+	PxI    float64
+	PxO    float64
+
+	// Fees per share of the entry order and of the exit order on the bar
+	FeeI   float64
+	FeeO   float64
+
+	// Commissions and other fees paid on the bar
+	Fees   float64
+
+	// Slippage cost on the bar, i.e. the loss from fills worse than the 
+	// quote (or the trade price)
+	Slip   float64
+
+	// Spread cost on the bar, i.e. the loss from fills at the quote rather 
+	// than at the mid price
+	Spread float64
+
+	// Protective exits on the bar, made ahead of the signal exit and included
+	// in the removals
+	Hit    Triggered
+
+	// Fractional shares cashed out on the bar in the whole-share mode, e.g.
+	// after a split
+	Lieu   Triggered
+
+	// The number of lots closed on the side so far
+	ClosedN int
+
+	// Wash-sale adjustments made on the bar and the losses not matched with
+	// replacement shares yet
+	Wash   []WashSale
+	Losses []washLoss
+
+	// A flag showing that re-entries are suspended after a protective exit 
+	// until the signal changes, and the signal (the size of position) at the
+	// exit
+	Halt   bool
+	Signal float64
+
 	// The results of trading
 	Result TReturn
 }
@@ -72,13 +162,13 @@ type Position struct {
 // IOE for signals (In-Out-End)
 type IOE struct {
 	// The size of a new bet (an addition to the position), entry signal
-	I int
+	I float64
 	
 	// The size of removed position, exit signal
-	O int
+	O float64
 	
 	// The resulting size of position
-	E int
+	E float64
 }
 
 // Prices for Close (Last) and Trade prices
@@ -88,6 +178,29 @@ type Prices struct {
 	
 	// Trade price
 	Tx float64
+
+	// Open, high and low prices; 0 if unknown
+	Op float64
+	Hi float64
+	Lo float64
+
+	// Bar range, i.e. the true range if intrabar prices are known, otherwise
+	// the absolute change of the close price since the previous bar
+	Rng float64
+
+	// Volume; 0 if unknown
+	Vol float64
+
+	// Average true range as at the end of the bar
+	ATR float64
+
+	// Standard deviation of close-to-close returns over the volatility window 
+	// as at the end of the previous bar; 0 if unknown
+	Sigma float64
+
+	// Bid and ask prices; 0 if unknown
+	Bid float64
+	Ask float64
 }
 
 // Tally describes changing amounts or values
@@ -126,7 +239,11 @@ type TReturn struct {
 // argsFIFO holds arguments for the fifo() function.
 type argsFIFO struct {
 	// Prices and signals
-	Sigs     []Trades
+	Bars     []Bar
+
+	// A flag showing that the short and the long books are held at the same 
+	// time (hedged mode)
+	Hedged   bool
 	
 	// Cash base (Cash Allocated for Trading) - assets initially allocated for 
 	// the trading program
@@ -134,93 +251,247 @@ type argsFIFO struct {
 	
 	// Cash limit of exposure per position
 	Lim      float64
+
+	// Reinvestment mode: no, nav or equity
+	Reinvest string
+
+	// Position sizing model
+	Sizing   SizingModel
+
+	// The lot size the quantities of new entries are rounded down to; 0 if
+	// quantities are not rounded
+	Round    float64
+
+	// Fraction of NAV or of realized equity exposed per position
+	Fraction float64
 	
-	// Broker's commission
-	Fee      float64
+	// Broker's commissions and other fees
+	Fees     CommissionModel
+
+	// Slippage and market impact
+	Slip     SlippageModel
+
+	// Marking of positions to market: close, mid or bidask
+	Mark     string
+
+	// Lot-relief policy
+	Relief   Relief
+
+	// Policy on entries overdrawing cash: allow, reject or scale
+	CashPolicy string
+
+	// Protective exits: stop-loss, take-profit and trailing stop
+	Stops    overlay
+
+	// The order of the high and the low within a bar: worst, high_first, 
+	// low_first or nearest
+	Intrabar string
+
+	// A flag showing that exits realizing a loss are blocked
+	NoLoss   bool
+
+	// The maximum number of bars in a row an exit may be blocked; 0 for no
+	// limit
+	MaxBlock int
+
+	// The number of bars between a signal and its execution
+	Delay    int
+
+	// A flag showing that the signals are order quantities rather than
+	// target positions
+	Delta    bool
+
+	// The number of days either side of a loss exit within which replacement
+	// lots wash the loss; 0 if the wash-sale mode is off
+	Wash     int
+
+	// Borrow fees and margin interest
+	Finance  financing
+}
+
+// account holds the values of the account as at the end of the previous bar,
+// which new entries are sized from.
+type account struct {
+	// Net Asset Value
+	NAV    float64
+
+	// Realized equity, i.e. NAV less unrealized returns
+	Equity float64
+
+	// Cash free for new entries
+	Free   float64
 }
 
 // fifo calculates results of model trade on the basis of signals.
-// Note: no error handling.
-func fifo(q argsFIFO) (values []Asset, err error) {
+// The calculation stops if the context is cancelled.
+func fifo(ctx context.Context, q argsFIFO) (values []Asset, err error) {
 	var (
 		this Asset
 	)
 	// Allocate space for a slice of trade results
-	values = make([]Asset, len(q.Sigs))
-
-	for i, signals := range q.Sigs {
-		// Put underlying asset's prices into the Asset object
-		this.prices(signals)
+	values = make([]Asset, len(q.Bars))
 
-		if i == 0 {
-			// Initial signals (bar 1)
-			this.iniSignals(signals)
+	for i, signals := range q.Bars {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
 
-			this.qtyStart(Asset{})
-			this.basisStart(Asset{})
-			this.additions(Asset{}, q)
-			this.qtyEnd()
-			this.basisEnd()
+		switch i {
+		case 0:
+			this.first(signals, q.start(), q)
 
-			// Starting Net Asset Value
-			this.NAV = q.Cashbase
+		default:
+			prev := values[i-1]
+			this.step(signals, prev, prev.account(), q)
 		}
+		values[i] = this
+	}
+	return
+}
 
-		if i > 0 {
-			// Put the signals into the Asset object
-			this.signals(signals, values[i-1])
+// account returns the values of the account at the end of the bar.
+func (this Asset) account() account {
+	return account{
+		NAV:    this.NAV,
+		Equity: this.NAV - this.S.Result.Unr - this.L.Result.Unr,
+		Free:   this.free(),
+	}
+}
 
-			// Ending position size
-			this.posEnd(values[i-1])
+// start returns the values of the account before the first bar.
+func (q argsFIFO) start() account {
+	return account{NAV: q.Cashbase, Equity: q.Cashbase, Free: q.Cashbase}
+}
 
-			// Starting quantity
-			this.qtyStart(values[i-1])
+// first calculates the results of the first bar. New entries are sized from 
+// the account values.
+func (this *Asset) first(signals Bar, acct account, q argsFIFO) {
+	this.N = 0
 
-			// Starting basis
-			this.basisStart(values[i-1])
+	// Put underlying asset's prices into the Asset object
+	this.prices(signals)
 
-			// New trades, opened positions
-			this.additions(values[i-1], q)
+	// Corporate actions of the first bar apply as well, though no positions
+	// are held before it
+	prev := this.corporate(signals, Asset{})
 
-			// Closed positions
-			this.removals(values[i-1])
+	// Initial signals (bar 1)
+	this.iniSignals(orders(signals, prev, q), q.Hedged)
 
-			// Ending quantity
-			this.qtyEnd()
+	this.qtyStart(prev)
+	this.basisStart(prev)
+	this.removals(prev, q)
+	this.additions(acct, q)
+	this.qtyEnd()
+	this.basisEnd()
+	this.costs()
 
-			// Ending basis
-			this.basisEnd()
+	// Cash balance
+	this.balance(prev, q.Cashbase)
 
-			// Mark to Market
-			this.mtm()
+	// Starting Net Asset Value
+	this.NAV = q.Cashbase
+}
 
-			// Net proceeds from position removal, closing an exact number of 
-			// positions
-			this.cfRemov(q.Fee)
+// step calculates the results of a bar following the previous one. New 
+// entries are sized from the account values as at the end of the previous bar.
+func (this *Asset) step(signals Bar, last Asset, acct account, q argsFIFO) {
+	this.N = last.N + 1
 
-			// Returns
-			this.returns(values[i-1])
+	// Put underlying asset's prices into the Asset object
+	this.prices(signals)
 
-			// Net Asset Value
-			this.assets(q.Cashbase)
+	// Corporate actions adjust the positions held since the previous bar
+	prev := this.corporate(signals, last)
 
-			// Peak Net Asset Value
-			this.maxAssets(values[i-1])
+	// The bar range
+	this.barRange(prev)
 
-			// Drawdown
-			this.drawdown(q.Cashbase)
+	// Average true range
+	this.avgRange(prev, q.Stops.bars)
 
-			// Worst (maximum) drawdown
-			this.wdd(values[i-1])
+	// Volatility of returns
+	this.volatility(prev, q.Sizing)
 
-			// Number of trades
-			// The counter of additions (initiated trades)
-			this.countEntries()
+	// Order quantities are applied to the positions held
+	signals = orders(signals, prev, q)
 
-			// The counter of removals (completed trades)
-			this.countExits()
-		}
-		values[i] = this
-	}
-	return
+	// Put the signals into the Asset object, unless re-entries are suspended
+	this.signals(this.suspend(signals, prev, q.Hedged), prev, q.Hedged)
+
+	// Ending position size
+	this.posEnd(prev)
+
+	// Exits blocked due to a possible loss
+	this.noLossExit(prev, q)
+
+	// Fractional shares are cashed out
+	prev = this.cashInLieu(signals, prev, q)
+
+	// Protective exits override the signals
+	prev = this.protect(signals, prev, q)
+
+	// Starting quantity
+	this.qtyStart(prev)
+
+	// Starting basis
+	this.basisStart(prev)
+
+	// Closed positions
+	// Note: A side never grows and declines on the same bar, so that exits
+	// go first and free up cash for new entries.
+	this.removals(prev, q)
+
+	// Net proceeds from position removal, closing an exact number of 
+	// positions
+	this.cfRemov()
+
+	// New trades, opened positions
+	this.additions(acct, q)
+
+	// Losses washed by replacement lots
+	this.washSales(prev, q)
+
+	// Best and worst prices since the entries
+	this.excursions()
+
+	// Ending quantity
+	this.qtyEnd()
+
+	// Ending basis
+	this.basisEnd()
+
+	// Mark to Market
+	this.mtm(q.Mark)
+
+	// Commissions and slippage paid
+	this.costs()
+
+	// Borrow fees and margin interest accrued
+	this.accrue(signals, prev, q)
+
+	// Cash balance
+	this.balance(prev, q.Cashbase)
+
+	// Returns
+	this.returns(prev)
+
+	// Net Asset Value
+	this.assets(q.Cashbase)
+
+	// Peak Net Asset Value
+	this.maxAssets(prev)
+
+	// Drawdown
+	this.drawdown(q.Cashbase)
+
+	// Worst (maximum) drawdown
+	this.wdd(prev)
+
+	// Number of trades
+	// The counter of additions (initiated trades)
+	this.countEntries()
+
+	// The counter of removals (completed trades)
+	this.countExits()
 }
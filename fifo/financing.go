@@ -0,0 +1,149 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Day-count conventions
+const (
+	dayCountAct360 string = "act/360"
+	dayCountAct365 string = "act/365"
+	dayCount30360  string = "30/360"
+	dayCountBars   string = "bars"
+)
+
+// Financing holds the settings of holding costs accrued on every bar: borrow
+// fees of short positions and interest on negative cash.
+type Financing struct {
+	// Annual borrow rate of short positions, e.g. 0.02; 0 for none. The
+	// borrow column of input files, if set, takes its place.
+	Borrow   float64 `yaml:"borrow_rate"`
+
+	// Annual interest rate charged on negative cash, e.g. 0.05; 0 for none
+	Margin   float64 `yaml:"margin_rate"`
+
+	// Day-count convention: act/360, act/365, 30/360 or bars; act/360 by
+	// default if the date layout is set, otherwise bars
+	DayCount string  `yaml:"day_count"`
+}
+
+// financing holds the checked settings of holding costs.
+type financing struct {
+	// Annual rates
+	borrow   float64
+	margin   float64
+
+	// A flag showing that the borrow rates are taken from the bars
+	column   bool
+
+	// Day-count convention
+	count    string
+
+	// The number of bars a year for the bars convention
+	perYear  float64
+}
+
+// newFinancing checks the settings of holding costs. Conventions other than
+// bars need the bars to be dated.
+func newFinancing(f Financing, column bool, dates string, perYear float64) (financing, error) {
+	var fin financing
+
+	if f.Borrow < 0 || f.Margin < 0 {
+		return fin, fmt.Errorf("borrow rate %v and margin rate %v must not be negative", f.Borrow, f.Margin)
+	}
+
+	switch count := strings.ToLower(strings.TrimSpace(f.DayCount)); count {
+	case "":
+		fin.count = dayCountBars
+		if len(dates) > 0 {
+			fin.count = dayCountAct360
+		}
+
+	case dayCountAct360, dayCountAct365, dayCount30360:
+		if len(dates) == 0 {
+			return fin, fmt.Errorf("day count '%s' needs the date layout", f.DayCount)
+		}
+		fin.count = count
+
+	case dayCountBars:
+		fin.count = count
+
+	default:
+		return fin, fmt.Errorf("unknown day count '%s'", f.DayCount)
+	}
+
+	fin.borrow, fin.margin = f.Borrow, f.Margin
+	fin.column  = column
+	fin.perYear = perYear
+	if fin.perYear <= 0 {
+		fin.perYear = barsPerYear
+	}
+	return fin, nil
+}
+
+// years returns the fraction of a year between the dates by the day-count
+// convention; a bar makes 1 / bars a year by the bars convention.
+func (fin financing) years(from, to time.Time) float64 {
+	switch fin.count {
+	case dayCountAct360:
+		return to.Sub(from).Hours() / 24 / 360
+
+	case dayCountAct365:
+		return to.Sub(from).Hours() / 24 / 365
+
+	case dayCount30360:
+		// Note: The 30/360 US (bond basis) rule for the ends of months.
+		d1, d2 := from.Day(), to.Day()
+		if d1 == 31 {
+			d1 = 30
+		}
+		if d2 == 31 && d1 == 30 {
+			d2 = 30
+		}
+		days := 360 * (to.Year() - from.Year()) + 30 * (int(to.Month()) - int(from.Month())) + d2 - d1
+		return float64(days) / 360
+
+	default:
+		return 1 / fin.perYear
+	}
+}
+
+// borrowFee returns the fee for borrowing the short stock of the value over
+// the fraction of a year. The rate of the bar takes the place of the flat
+// rate if the borrow column is set.
+func (fin financing) borrowFee(value, rate, years float64) float64 {
+	if !fin.column {
+		rate = fin.borrow
+	}
+	return math.Abs(value) * rate * years
+}
+
+// interest returns the interest on the negative cash over the fraction of a
+// year; 0 if the cash is not negative.
+func (fin financing) interest(cash, years float64) float64 {
+	if cash >= 0 {
+		return 0
+	}
+	return -cash * fin.margin * years
+}
+
+// accrue accrues the holding costs of the period since the previous bar: the
+// borrow fee of the short stock held, valued at the close price of the
+// previous bar (adjusted for splits), and the interest on the negative cash
+// balance at the end of the previous bar. The costs are paid out of the cash.
+func (this *Asset) accrue(bar Bar, prev Asset, q argsFIFO) {
+	years := q.Finance.years(prev.Date, this.Date)
+
+	this.Borrow   = q.Finance.borrowFee(prev.S.Qty.E * prev.Pxs.Cl, bar.Borrow, years)
+	this.Interest = q.Finance.interest(prev.Cash, years)
+}
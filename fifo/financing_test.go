@@ -0,0 +1,117 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewFinancing(t *testing.T) {
+	tests := []struct {
+		name    string
+		fin     Financing
+		dates   string
+		perYear float64
+		ok      bool
+		count   string
+		bars    float64
+	}{
+		{"dated by default", Financing{}, "2006-01-02", 0, true, dayCountAct360, barsPerYear},
+		{"undated by default", Financing{}, "", 0, true, dayCountBars, barsPerYear},
+		{"bars a year set", Financing{DayCount: "bars"}, "", 52, true, dayCountBars, 52},
+		{"act/365", Financing{DayCount: " ACT/365 "}, "2006-01-02", 0, true, dayCountAct365, barsPerYear},
+		{"30/360", Financing{DayCount: "30/360"}, "2006-01-02", 0, true, dayCount30360, barsPerYear},
+		{"act/360 undated", Financing{DayCount: "act/360"}, "", 0, false, "", 0},
+		{"unknown", Financing{DayCount: "act/act"}, "2006-01-02", 0, false, "", 0},
+		{"negative borrow rate", Financing{Borrow: -0.02}, "", 0, false, "", 0},
+		{"negative margin rate", Financing{Margin: -0.05}, "", 0, false, "", 0},
+	}
+	for _, tt := range tests {
+		fin, err := newFinancing(tt.fin, false, tt.dates, tt.perYear)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if tt.ok && (fin.count != tt.count || fin.perYear != tt.bars) {
+			t.Errorf("%s: day count %s, %v bars a year; want %s, %v", tt.name, fin.count, fin.perYear, tt.count, tt.bars)
+		}
+	}
+}
+
+func TestYears(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		count    string
+		from, to string
+		want     float64
+	}{
+		{dayCountAct360, "2020-01-02", "2020-01-03", 1.0 / 360},
+		{dayCountAct360, "2020-01-03", "2020-01-06", 3.0 / 360},
+		{dayCountAct360, "2020-01-01", "2021-01-01", 366.0 / 360},
+		{dayCountAct365, "2020-01-03", "2020-01-06", 3.0 / 365},
+		{dayCountAct365, "2021-01-01", "2022-01-01", 1},
+		{dayCount30360, "2020-01-15", "2020-02-15", 30.0 / 360},
+		{dayCount30360, "2020-02-28", "2020-03-02", 4.0 / 360},
+		{dayCount30360, "2020-01-31", "2020-02-01", 1.0 / 360},
+		{dayCount30360, "2020-03-30", "2020-03-31", 0},
+		{dayCount30360, "2020-03-29", "2020-03-31", 2.0 / 360},
+		{dayCount30360, "2020-01-31", "2020-03-31", 60.0 / 360},
+		{dayCount30360, "2020-12-31", "2021-01-04", 4.0 / 360},
+		{dayCountBars, "2020-01-03", "2020-01-06", 1.0 / 252},
+	}
+	for _, tt := range tests {
+		fin := financing{count: tt.count, perYear: 252}
+		if got := fin.years(day(tt.from), day(tt.to)); math.Abs(got - tt.want) > 1e-12 {
+			t.Errorf("%s from %s to %s: years = %v, want %v", tt.count, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestBorrowFee(t *testing.T) {
+	tests := []struct {
+		name   string
+		column bool
+		value  float64
+		rate   float64
+		want   float64
+	}{
+		{"flat rate", false, -1e6, 0.10, 1e6 * 0.02 / 360},
+		{"flat rate, the rate of the bar ignored", false, -1e6, 0, 1e6 * 0.02 / 360},
+		{"rate of the bar", true, -1e6, 0.10, 1e6 * 0.10 / 360},
+		{"no rate on the bar", true, -1e6, 0, 0},
+		{"no short stock", false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		fin := financing{borrow: 0.02, column: tt.column, count: dayCountAct360}
+		if got := fin.borrowFee(tt.value, tt.rate, 1.0 / 360); math.Abs(got - tt.want) > 1e-9 {
+			t.Errorf("%s: borrowFee = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInterest(t *testing.T) {
+	fin := financing{margin: 0.05, count: dayCountAct360}
+
+	tests := []struct {
+		cash  float64
+		years float64
+		want  float64
+	}{
+		{-50e6, 1.0 / 360, 6944.444444444444},
+		{-50e6, 3.0 / 360, 3 * 6944.444444444444},
+		{0, 1.0 / 360, 0},
+		{50e6, 1.0 / 360, 0},
+	}
+	for _, tt := range tests {
+		if got := fin.interest(tt.cash, tt.years); math.Abs(got - tt.want) > 1e-6 {
+			t.Errorf("interest(%v, %v) = %v, want %v", tt.cash, tt.years, got, tt.want)
+		}
+	}
+}
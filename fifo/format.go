@@ -8,9 +8,10 @@
 package fifo
 
 import (
-	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
+	"time"
 )
 
 // Trades for signals read from a CSV file; it contains 
@@ -28,93 +29,196 @@ type Trades struct {
 	
 	// The side and the size of position
 	SL string
+
+	// The sizes of short and long positions (hedged mode)
+	Sh string
+	Ln string
+
+	// Open, high and low prices (optional)
+	Op string
+	Hi string
+	Lo string
+
+	// Volume (optional)
+	Vol string
+
+	// Bid and ask prices (optional)
+	Bd string
+	Ak string
+
+	// Annual borrow rate of the short stock (optional)
+	Br string
+
+	// Symbol (optional)
+	Sym string
 }
 
-// getTrades reads the CSV data file into a slice of Trades objects. 
-// No data validation.
-func getTrades(file string, headers bool) ([]Trades, error) {
-	var (
-		sig []Trades
-	)
-	raw, err := csv2data(file, headers)
-	if err != nil {
-		msg := "CSV data error!"
-		warning(msg, err)
-		return sig, err
-	}
-	sig = data2trades(raw)
-	// fmt.Printf("Read signals into the object of type: %T\n", sig)
-	return sig, nil
+// Bar holds prices and the signal of a single bar, parsed from Trades.
+type Bar struct {
+	// Bar ID, such as date/time stamp in any convenient format
+	ID       string
+
+	// ID of the bar the signal traded on the bar comes from; set by the 
+	// calculation, the bar's own ID unless the execution is delayed
+	SignalID string
+
+	// The date of the bar parsed from its ID by the date layout; set by the 
+	// calculation, zero if no date layout is set
+	Date     time.Time
+
+	// Symbol of the instrument; empty if the input holds a single instrument
+	Symbol   string
+
+	// Close (last) price
+	Close    float64
+
+	// Trade price
+	Trade    float64
+
+	// The side and the size of position: negative for 'short', positive for 
+	// 'long' positions; the order quantity in the delta signal mode
+	Position float64
+
+	// The sizes of short and long positions in the hedged mode, both books 
+	// are held at the same time; the order quantities of the books in the 
+	// delta signal mode
+	Short    float64
+	Long     float64
+
+	// Open, high and low prices; 0 if unknown
+	Open     float64
+	High     float64
+	Low      float64
+
+	// Volume traded on the bar; 0 if unknown
+	Volume   float64
+
+	// Bid and ask prices; 0 if unknown
+	Bid      float64
+	Ask      float64
+
+	// Annual borrow rate of the short stock; 0 if unknown
+	Borrow   float64
+
+	// Corporate actions taking effect at the start of the bar: the number of 
+	// new shares per old share (0 if there is no split) and cash dividend per 
+	// share
+	Split    float64
+	Dividend float64
 }
 
-// csv2data reads file and puts csv data into a [][]string matrix (raws-columns)
-func csv2data(file string, headers bool) ([][]string, error) {
-	fmt.Printf("Reading trade signals from file %s ...\n", file)
-	csvData, err := readCSV(file)
+// ReadBars reads bars in the CSV format, columns as in input files. 
+// The input is validated, and the problems found are reported with the name
+// of the input, line and column numbers. In the strict validation mode, 
+// problems are returned as an error; in the lenient mode, bad rows are skipped 
+// and problems are returned as warnings.
+func ReadBars(r io.Reader, name string, par Params) ([]Bar, Problems, error) {
+	records, problems, err := readRecords(r, name)
 	if err != nil {
-		msg := "Failed to read data from a trade signal file!"
-		warning(msg, err)
-		return csvData, err
+		return nil, problems, err
 	}
-	fmt.Printf("First row in %s: %v\n", file, csvData[0])
-
-	switch headers {
-	case true:
-		fmt.Printf("Deleting first row (assumed column titles): %v\n", csvData[0])
-
-		// Delete the title row (first element from the slice)
-		csvData = append(csvData[:0], csvData[1:]...)
-		fmt.Printf("First data row: %v\n", csvData[0])
-		return csvData, nil
+	return recordBars(records, problems, name, par)
+}
 
-	default:
-		fmt.Printf("First data row: %v\n", csvData[0])
-		return csvData, nil
+// recordBars converts the rows read into bars, see ReadBars.
+func recordBars(records []record, problems Problems, name string, par Params) ([]Bar, Problems, error) {
+	if par.Headers && len(records) > 0 {
+		records = records[1:]
+	}
+	if len(records) == 0 {
+		problems = append(problems, Problem{File: name, Msg: "no data rows found"})
+		return nil, problems, problems
 	}
+	return validate(records, problems, name, par)
 }
 
-// data2trades puts data from a matrix of read input into a slice of 
-// Trades objects. No data validation.
-func data2trades(csvData [][]string) []Trades {
-	var (
-		one Trades
-		all []Trades
-	)
-	all = make([]Trades, len(csvData))
-	for i, each := range csvData {
-		one = Trades{
-			Dt: each[0], //col1
-			Cl: each[1], //col2
-			Tx: each[2], //col3
-			SL: each[3], //col4
+// ParseTrades converts Trades objects into bars. The objects are validated 
+// as rows of an input file, numbered from 1.
+func ParseTrades(sigs []Trades, par Params) ([]Bar, Problems, error) {
+	layout := par.Columns.withDefaults(par.Hedged)
+
+	records := make([]record, len(sigs))
+	for i, one := range sigs {
+		records[i] = record{
+			Line:   i + 1,
+			Fields: trades2data(one, layout),
 		}
-		all[i] = one
 	}
-	return all
+	return validate(records, nil, "", par)
 }
 
-// readCSV reads data from each csv file into a [][]string matrix
-func readCSV(filename string) ([][]string, error) {
-	var csvData [][]string
+// readBars reads the CSV data file into a slice of bars.
+func readBars(file string, par Params) ([]Bar, Problems, error) {
+	fmt.Printf("Reading trade signals from file %s ...\n", file)
 
-	csvFile, errOpen := os.Open(filename)
-	if errOpen != nil {
-		msgOpen := "Failed to open a trade signal file!"
-		warning(msgOpen, errOpen)
-		return csvData, errOpen
+	csvFile, err := os.Open(file)
+	if err != nil {
+		msg := "Failed to open a trade signal file!"
+		warning(msg, err)
+		return nil, nil, err
 	}
-
 	defer csvFile.Close()
 
-	reader := csv.NewReader(csvFile)
-	reader.FieldsPerRecord = -1
+	records, problems, err := readRecords(csvFile, file)
+	if err != nil {
+		return nil, problems, err
+	}
+	// Note: The rows are echoed as read, before validation.
+	if len(records) > 0 {
+		fmt.Printf("First row in %s: %v\n", file, records[0].Fields)
+	}
+	data := records
+	if par.Headers && len(data) > 0 {
+		data = data[1:]
+	}
+	if len(data) > 0 {
+		fmt.Printf("First data row: %v\n", data[0].Fields)
+	}
+	return recordBars(records, problems, file, par)
+}
 
-	csvData, errRead := reader.ReadAll()
-	if errRead != nil {
-		msgRead := "Failed to read a trade signal file!"
-		warning(msgRead, errRead)
-		os.Exit(1)
-		return csvData, errRead
+// data2trades puts data from a row of read input into a Trades object. 
+// No data validation.
+func data2trades(each []string, layout Layout) Trades {
+	return Trades{
+		Dt: field(each, layout.Bar),
+		Cl: field(each, layout.Close),
+		Tx: field(each, layout.Trade),
+		SL: field(each, layout.Position),
+		Sh: field(each, layout.Short),
+		Ln: field(each, layout.Long),
+		Op: field(each, layout.Open),
+		Hi: field(each, layout.High),
+		Lo: field(each, layout.Low),
+		Vol: field(each, layout.Volume),
+		Bd: field(each, layout.Bid),
+		Ak: field(each, layout.Ask),
+		Br: field(each, layout.Borrow),
+		Sym: field(each, layout.Symbol),
+	}
+}
+
+// trades2data puts data from a Trades object into a row as in input files.
+func trades2data(one Trades, layout Layout) []string {
+	each := make([]string, layout.width())
+	put := func(col int, value string) {
+		if col > 0 {
+			each[col-1] = value
+		}
 	}
-	return csvData, nil
+	put(layout.Bar, one.Dt)
+	put(layout.Close, one.Cl)
+	put(layout.Trade, one.Tx)
+	put(layout.Position, one.SL)
+	put(layout.Short, one.Sh)
+	put(layout.Long, one.Ln)
+	put(layout.Open, one.Op)
+	put(layout.High, one.Hi)
+	put(layout.Low, one.Lo)
+	put(layout.Volume, one.Vol)
+	put(layout.Bid, one.Bd)
+	put(layout.Ask, one.Ak)
+	put(layout.Borrow, one.Br)
+	put(layout.Symbol, one.Sym)
+	return each
 }
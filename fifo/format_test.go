@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBorrowColumn(t *testing.T) {
+	par := Params{Columns: Layout{Borrow: 5}}
+
+	tests := []struct {
+		name   string
+		input  string
+		ok     bool
+		borrow []float64
+	}{
+		{
+			name:   "rates",
+			input:  "2020-01-02,100,100,-1,0.02\n2020-01-03,101,101,-1,0.035\n2020-01-06,99,99,0,0\n",
+			ok:     true,
+			borrow: []float64{0.02, 0.035, 0},
+		},
+		{
+			name:  "rate not a number",
+			input: "2020-01-02,100,100,-1,n/a\n",
+			ok:    false,
+		},
+		{
+			name:  "negative rate",
+			input: "2020-01-02,100,100,-1,-0.02\n",
+			ok:    false,
+		},
+		{
+			name:  "borrow column missing",
+			input: "2020-01-02,100,100,-1\n",
+			ok:    false,
+		},
+	}
+	for _, tt := range tests {
+		bars, _, err := ReadBars(strings.NewReader(tt.input), tt.name, par)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v, want ok %v", tt.name, err, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if len(bars) != len(tt.borrow) {
+			t.Fatalf("%s: %d bars, want %d", tt.name, len(bars), len(tt.borrow))
+		}
+
+		// The same rows given as Trades objects
+		sigs := make([]Trades, len(bars))
+		for i, each := range strings.Split(strings.TrimSpace(tt.input), "\n") {
+			sigs[i] = data2trades(strings.Split(each, ","), par.Columns.withDefaults(false))
+		}
+		parsed, _, err := ParseTrades(sigs, par)
+		if err != nil {
+			t.Fatalf("%s: ParseTrades: %v", tt.name, err)
+		}
+
+		for i, want := range tt.borrow {
+			if bars[i].Borrow != want || parsed[i].Borrow != want {
+				t.Errorf("%s: bar %d borrow rates %v and %v, want %v", tt.name, i, bars[i].Borrow, parsed[i].Borrow, want)
+			}
+		}
+	}
+}
+
+func TestTradesRoundTrip(t *testing.T) {
+	layout := Layout{Open: 5, High: 6, Low: 7, Volume: 8, Bid: 9, Ask: 10, Borrow: 11, Symbol: 12}.withDefaults(false)
+	row    := []string{"2020-01-02", "100", "100.5", "-1", "99", "101", "98", "12000", "100.4", "100.6", "0.02", "XYZ"}
+
+	got := trades2data(data2trades(row, layout), layout)
+	if strings.Join(got, ",") != strings.Join(row, ",") {
+		t.Errorf("round trip = %v, want %v", got, row)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Assumptions on the order of the high and the low within a bar
+const (
+	// The extreme adverse to the position is reached first
+	orderWorst     string = "worst"
+	// Open, high, low, close
+	orderHighFirst string = "high_first"
+	// Open, low, high, close
+	orderLowFirst  string = "low_first"
+	// The extreme nearer to the open is reached first
+	orderNearest   string = "nearest"
+)
+
+// intrabarOrder returns the order of the high and the low within a bar by its
+// config name: 'worst' (default), 'high_first', 'low_first' or 'nearest'.
+func intrabarOrder(name string) (string, error) {
+	switch order := strings.ToLower(strings.TrimSpace(name)); order {
+	case "":
+		return orderWorst, nil
+
+	case orderWorst, orderHighFirst, orderLowFirst, orderNearest:
+		return order, nil
+
+	default:
+		return "", fmt.Errorf("unknown intrabar order '%s'", name)
+	}
+}
+
+// adverseFirst tells whether the extreme of the bar adverse to a position is
+// reached before the favourable one; dir is positive for long positions.
+func adverseFirst(order string, dir float64, pxs Prices) bool {
+	open := pxs.open()
+
+	switch order {
+	case orderHighFirst:
+		return dir < 0
+
+	case orderLowFirst:
+		return dir > 0
+
+	case orderNearest:
+		// Note: The high goes first if it is nearer to the open.
+		return (pxs.high() - open <= open - pxs.low()) == (dir < 0)
+
+	default:
+		return true
+	}
+}
+
+// excursions for the best and the worst prices since the entries of the lots
+// held at the end of the bar. The lots entered on the bar keep their entry
+// prices.
+func (this *Asset) excursions() {
+	// Note: The high is the best price for long lots and the worst one for
+	// short lots.
+	this.S.Queue = extremes(this.S.Queue, this.N, this.Pxs.low(), this.Pxs.high())
+	this.L.Queue = extremes(this.L.Queue, this.N, this.Pxs.high(), this.Pxs.low())
+}
+
+// extremes returns a copy of the queue with the best and the worst prices
+// updated by the prices of the bar.
+// Note: A copy is updated, so that the lots shared with the queue of the
+// previous bar are left intact.
+func extremes(queue []Pending, n int, best, worst float64) []Pending {
+	if len(queue) == 0 {
+		return queue
+	}
+	updated := make([]Pending, len(queue))
+	for i, one := range queue {
+		if one.N != n {
+			one.Peak  = better(one.Qty, one.Peak, best)
+			one.Worst = better(-one.Qty, one.Worst, worst)
+		}
+		updated[i] = one
+	}
+	return updated
+}
+
+// better returns the better one of two prices for a lot of the quantity: the
+// higher for long lots, the lower for short lots.
+func better(qty, x, y float64) float64 {
+	if qty < 0 {
+		return math.Min(x, y)
+	}
+	return math.Max(x, y)
+}
+
+// excursion returns the maximum adverse and the maximum favourable excursions
+// of a lot closed at the price, i.e. the worst and the best unrealized
+// returns before fees while it was held.
+func excursion(lot Pending, px float64) (float64, float64) {
+	qty   := math.Abs(lot.Qty)
+	entry := lot.entry()
+
+	// Note: Sign convention. The short stock has a negative quantity.
+	best  := better(lot.Qty, lot.Peak, px)
+	worst := better(-lot.Qty, lot.Worst, px)
+	if lot.Qty < 0 {
+		return qty * math.Max(worst - entry, 0), qty * math.Max(entry - best, 0)
+	}
+	return qty * math.Max(entry - worst, 0), qty * math.Max(best - entry, 0)
+}
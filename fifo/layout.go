@@ -0,0 +1,99 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+// Layout holds the numbers (starting from 1) of columns in input files.
+// Zero values stand for default columns.
+type Layout struct {
+	// Bar ID, 1 by default
+	Bar      int `yaml:"bar"`
+
+	// Close (last) price, 2 by default
+	Close    int `yaml:"close"`
+
+	// Trade price, 3 by default
+	Trade    int `yaml:"trade"`
+
+	// The side and the size of position, 4 by default; not used in the
+	// hedged mode
+	Position int `yaml:"position"`
+
+	// The size of short position in the hedged mode, 4 by default
+	Short    int `yaml:"short"`
+
+	// The size of long position in the hedged mode, 5 by default
+	Long     int `yaml:"long"`
+
+	// Open, high and low prices (optional), no defaults
+	Open     int `yaml:"open"`
+	High     int `yaml:"high"`
+	Low      int `yaml:"low"`
+
+	// Volume (optional), no default
+	Volume   int `yaml:"volume"`
+
+	// Bid and ask prices (optional), no defaults
+	Bid      int `yaml:"bid"`
+	Ask      int `yaml:"ask"`
+
+	// Annual borrow rate of the short stock (optional), no default
+	Borrow   int `yaml:"borrow"`
+
+	// Symbol (optional) of a long-format file holding several instruments, 
+	// no default
+	Symbol   int `yaml:"symbol"`
+}
+
+// withDefaults fills in default column numbers. In the hedged mode, separate
+// short and long columns are used in place of the position column.
+func (this Layout) withDefaults(hedged bool) Layout {
+	fill := func(col *int, def int) {
+		if *col <= 0 {
+			*col = def
+		}
+	}
+	fill(&this.Bar, 1)
+	fill(&this.Close, 2)
+	fill(&this.Trade, 3)
+
+	switch hedged {
+	case true:
+		this.Position = 0
+		fill(&this.Short, 4)
+		fill(&this.Long, 5)
+
+	default:
+		fill(&this.Position, 4)
+		this.Short = 0
+		this.Long  = 0
+	}
+	return this
+}
+
+// width returns the number of columns expected, i.e. the highest column
+// number in use.
+func (this Layout) width() int {
+	n := 0
+	for _, col := range []int{this.Bar, this.Close, this.Trade, this.Position,
+		this.Short, this.Long, this.Open, this.High, this.Low, this.Volume, 
+		this.Bid, this.Ask, this.Borrow, this.Symbol} {
+		if col > n {
+			n = col
+		}
+	}
+	return n
+}
+
+// field returns the value in the column, or an empty string if the column
+// is not in use.
+func field(fields []string, col int) string {
+	if col <= 0 || col > len(fields) {
+		return ""
+	}
+	return fields[col-1]
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sides of positions as named in the ledger
+const (
+	short string = "SHORT"
+	long  string = "LONG"
+)
+
+var (
+	ledgerAttributes string = `Side
+EntryBar
+ExitBar
+EntryCost
+ExitPrice
+Size
+Quantity
+Basis
+Realized
+Fees
+Slippage
+Bars
+ExitBy
+MAE
+MFE`
+)
+
+// Closed is a ledger entry for a lot (or a part of a lot) of positions closed
+// on a bar.
+type Closed struct {
+	// Symbol of the instrument in the portfolio mode; empty otherwise
+	Symbol   string
+
+	// The side of the lot, 'SHORT' or 'LONG'
+	Side     string
+
+	// Bar IDs of the entry and the exit
+	EntryBar string
+	ExitBar  string
+
+	// Bar numbers of the entry and the exit (zero-based)
+	EntryN   int
+	ExitN    int
+
+	// The dates of the entry and the exit; zero if no date layout is set
+	Acquired time.Time
+	Sold     time.Time
+
+	// The size of the closed lot, i.e. the number of positions in it
+	Size     float64
+
+	// The net cost price per share at the entry, fee accounted
+	Cost     float64
+
+	// The net price per share at the exit, fee accounted
+	Price    float64
+
+	// The quantity of shares closed
+	Qty      float64
+
+	// The basis of the closed lot
+	Basis    float64
+
+	// The realized result
+	Rzd      float64
+
+	// Commissions and other fees paid at the entry and at the exit
+	Fees     float64
+
+	// Slippage cost at the entry and at the exit
+	Slip     float64
+
+	// The kind of exit: signal, stop, trail or target; lieu for fractional
+	// shares cashed out
+	Exit     string
+
+	// Maximum adverse and favourable excursions, i.e. the worst and the best
+	// unrealized returns before fees while the lot was held
+	MAE      float64
+	MFE      float64
+
+	// The number of the lot among the lots closed on the side so far, 
+	// starting from 1
+	Seq      int
+
+	// The loss disallowed by the wash-sale rule, a positive amount; set after
+	// the calculation
+	Wash     float64
+
+	// The losses disallowed on other lots and added to the tax basis of the
+	// lot as a replacement by the wash-sale rule; the basis is left as is
+	WashAdj  float64
+}
+
+// Bars returns the holding period in bars.
+func (this Closed) Bars() int {
+	return this.ExitN - this.EntryN
+}
+
+// closures makes ledger entries for the lots removed from the queue. The fill
+// price, the fee and the slippage per share of the exit order are shared by 
+// the lots.
+func (this *Asset) closures(lots []Pending, side string, px, fee, slip float64) []Closed {
+	var (
+		price float64
+		cf    float64
+	)
+	closed := make([]Closed, len(lots))
+
+	for i, lot := range lots {
+		qty := math.Abs(lot.Qty)
+
+		switch side {
+		case short:
+			// Note: SHORT ==> negative proceeds; buying to close short position;
+			// price paid, fee added
+			price = px + fee
+			cf    = -qty * price
+
+		default:
+			// Note: LONG ==> positive proceeds; selling to close long position;
+			// price received, fees subtracted
+			price = px - fee
+			cf    = +qty * price
+		}
+
+		mae, mfe := excursion(lot, px)
+
+		closed[i] = Closed{
+			Side:     side,
+			EntryBar: lot.Bar,
+			ExitBar:  this.Bar,
+			EntryN:   lot.N,
+			ExitN:    this.N,
+			Acquired: lot.Acquired,
+			Sold:     this.Date,
+			Size:     lot.Size,
+			Cost:     lot.Cost,
+			Price:    price,
+			Qty:      lot.Qty,
+			Basis:    lot.Basis,
+			Rzd:      cf - lot.Basis,
+			WashAdj:  lot.WashAdj,
+			Fees:     qty * (lot.Fee + fee),
+			Slip:     qty * (lot.Slip + slip),
+			Exit:     exitSignal,
+			MAE:      mae,
+			MFE:      mfe,
+		}
+	}
+	return closed
+}
+
+// ledger collects ledger entries of both sides in the order of closing.
+func ledger(allRecords []Asset) []Closed {
+	var all []Closed
+	for _, one := range allRecords {
+		all = append(all, one.S.Closed...)
+		all = append(all, one.L.Closed...)
+	}
+	return all
+}
+
+// writeCSVledger exports the ledger of closed lots in the CSV format. Symbols
+// are put into the first column in the portfolio mode.
+func writeCSVledger(closed []Closed, symbols bool, outFile string) {
+	var (
+		field []string
+	)
+
+	csvNewFile, err := createCSV(outFile)
+	if err != nil {
+		fmt.Println("Output file creating error:", err)
+		return
+	}
+	defer csvNewFile.Close()
+
+	writer := csv.NewWriter(csvNewFile)
+
+	headers := strings.Split(ledgerAttributes, "\n")
+	if symbols {
+		writer.Write(append([]string{"Symbol"}, headers...))
+	} else {
+		writer.Write(headers)
+	}
+
+	for _, one := range closed {
+		field = make([]string, len(headers))
+
+		field[0] = one.Side
+		field[1] = one.EntryBar
+		field[2] = one.ExitBar
+		field[3] = fmt.Sprintf("%f", one.Cost)
+		field[4] = fmt.Sprintf("%f", one.Price)
+		field[5] = formatSize(one.Size)
+		field[6] = fmt.Sprintf("%f", one.Qty)
+		field[7] = fmt.Sprintf("%f", one.Basis)
+		field[8] = fmt.Sprintf("%f", one.Rzd)
+		field[9] = fmt.Sprintf("%f", one.Fees)
+		field[10] = fmt.Sprintf("%f", one.Slip)
+		field[11] = strconv.Itoa(one.Bars())
+		field[12] = one.Exit
+		field[13] = fmt.Sprintf("%f", one.MAE)
+		field[14] = fmt.Sprintf("%f", one.MFE)
+
+		if symbols {
+			field = append([]string{one.Symbol}, field...)
+		}
+		writer.Write(field)
+	}
+	writer.Flush()
+}
@@ -8,44 +8,205 @@
 package fifo
 
 import (
+	"context"
 	"fmt"
+	"path/filepath"
+	"strings"
 )
 
 // Model runs trade result calculations based on the history of trade signals
-// passed as data files.
-func Model(sigFile, outFile string, par Params) {
+// passed as data files. Optional outputs are written only if file names are 
+// given for them.
+func Model(files Files, par Params) {
 	fmt.Printf("\nHeaders (%T): %v\n", par.Headers, par.Headers)
+	fmt.Printf("Hedged (%T): %v\n", par.Hedged, par.Hedged)
 	
-	sigs, errSig := getTrades(sigFile, par.Headers)
-	if errSig != nil {
-		msgSig := "Signal read failed!"
-		warning(msgSig, errSig)
+	bars, ok := loadBars(files.Signals, files.Actions, par)
+	if !ok {
 		return
 	}
 
-	// Parameters:
-	fmt.Printf("Cash initially allocated for trading (%T): %v\n", par.Cash, par.Cash)
-	fmt.Printf("Limit of exposure per position (%T)      : %v\n", par.Lim, par.Lim)
-	fmt.Printf("Fee (%T): %v\n", par.Fee, par.Fee)
-
-	results, errFIFO := fifo(argsFIFO{
-		Sigs:     sigs,
-		Cashbase: par.Cash,
-		Lim:      par.Lim,
-		Fee:      par.Fee,
-	})
-	if errFIFO != nil {
-		msgFIFO := "Ups-a-daisy... Calculation failed!"
-		warning(msgFIFO, errFIFO)
+	printParams(par)
+
+	res, errSim := Simulate(context.Background(), bars, par)
+	for _, one := range res.Problems {
+		fmt.Printf("WARNING! Bar skipped: %v\n", one)
+	}
+	if errSim != nil {
+		msgSim := "Ups-a-daisy... Calculation failed!"
+		warning(msgSim, errSim)
 		return
 	}
+	results := res.Assets
 
+	fmt.Printf("Lot relief policy: %s\n", res.Relief)
 	// fmt.Println("Records in results:", len(results))
 	fmt.Println("Starting NAV:", results[0].NAV)
 	fmt.Printf("Ending NAV  : %v on %s\n", results[len(results)-1].NAV, results[len(results)-1].Bar)
 	fmt.Printf("Number of finished trades: %v on %s\n", results[len(results)-1].ExitN, results[len(results)-1].Bar)
 
-	writeCSVbasic(results, outFile)
+	printSummary(res.Summary)
+
+	writeCSVbasic(results, par.optional(len(files.Actions) > 0), files.Results)
+
+	if len(files.Ledger) > 0 {
+		writeCSVledger(res.Ledger, false, files.Ledger)
+	}
+	if len(files.Summary) > 0 {
+		writeCSVsummary(res.Summary, files.Summary)
+	}
+	if len(files.TaxLots) > 0 {
+		name := strings.TrimSuffix(filepath.Base(files.Signals), filepath.Ext(files.Signals))
+		writeCSVtaxLots(res.Ledger, name, files.TaxLots)
+	}
+	if len(files.TaxReport) > 0 {
+		writeCSVtaxReport(res.Tax, files.TaxReport)
+	}
+	if len(files.Wash) > 0 {
+		writeCSVwash(res.Wash, false, files.Wash)
+	}
+}
+
+// ModelPortfolio runs trade result calculations of several instruments 
+// trading against a single cash base. Each input file holds a single 
+// instrument, named by the file, or several instruments in the long format 
+// (with a symbol column).
+func ModelPortfolio(files PortfolioFiles, par Params) {
+	var instruments []Instrument
+
+	fmt.Printf("\nHeaders (%T): %v\n", par.Headers, par.Headers)
+	fmt.Printf("Hedged (%T): %v\n", par.Hedged, par.Hedged)
+
+	for i, file := range files.Signals {
+		var actions string
+		if i < len(files.Actions) {
+			actions = files.Actions[i]
+		}
+		bars, ok := loadBars(file, actions, par)
+		if !ok {
+			return
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		instruments = append(instruments, Instruments(bars, name)...)
+	}
+
+	printParams(par)
+
+	res, errSim := SimulatePortfolio(context.Background(), instruments, par)
+	for _, one := range res.Problems {
+		fmt.Printf("WARNING! Bar skipped: %v\n", one)
+	}
+	if errSim != nil {
+		msgSim := "Ups-a-daisy... Calculation failed!"
+		warning(msgSim, errSim)
+		return
+	}
+	last := res.Books[len(res.Books)-1].Total
+
+	fmt.Printf("Portfolio of %d instruments: %s\n", len(res.Symbols), strings.Join(res.Symbols, ", "))
+	fmt.Printf("Lot relief policy: %s\n", res.Relief)
+	fmt.Println("Starting NAV:", res.Books[0].Total.NAV)
+	fmt.Printf("Ending NAV  : %v on %s\n", last.NAV, last.Bar)
+	fmt.Printf("Number of finished trades: %v on %s\n", last.ExitN, last.Bar)
+
+	printSummary(res.Summary)
+
+	writeCSVportfolio(res, par.optional(false), files.Results)
+
+	if len(files.Ledger) > 0 {
+		writeCSVledger(res.Ledger, true, files.Ledger)
+	}
+	if len(files.Summary) > 0 {
+		writeCSVsummary(res.Summary, files.Summary)
+	}
+	if len(files.TaxLots) > 0 {
+		writeCSVtaxLots(res.Ledger, "", files.TaxLots)
+	}
+	if len(files.TaxReport) > 0 {
+		writeCSVtaxReport(res.Tax, files.TaxReport)
+	}
+	if len(files.Wash) > 0 {
+		writeCSVwash(res.Wash, true, files.Wash)
+	}
+}
+
+// loadBars reads the input file and applies the corporate actions, if any. 
+// Problems are printed as warnings.
+func loadBars(signals, actionsFile string, par Params) ([]Bar, bool) {
+	bars, problems, errSig := readBars(signals, par)
+	if errSig != nil {
+		msgSig := "Signal read failed!"
+		warning(msgSig, errSig)
+		return nil, false
+	}
+	for _, one := range problems {
+		fmt.Printf("WARNING! Row skipped: %v\n", one)
+	}
+
+	if len(actionsFile) > 0 {
+		actions, problems, errAct := readActions(actionsFile, par)
+		if errAct != nil {
+			msgAct := "Corporate actions read failed!"
+			warning(msgAct, errAct)
+			return nil, false
+		}
+		for _, one := range problems {
+			fmt.Printf("WARNING! Row skipped: %v\n", one)
+		}
+
+		if errAct = ApplyActions(bars, actions); errAct != nil {
+			msgAct := "Corporate actions not applied!"
+			warning(msgAct, errAct)
+			return nil, false
+		}
+		fmt.Printf("Corporate actions: %d\n", len(actions))
+	}
+
+	return bars, true
+}
+
+// printParams prints the parameters of calculation.
+func printParams(par Params) {
+	fmt.Printf("Cash initially allocated for trading (%T): %v\n", par.Cash, par.Cash)
+	fmt.Printf("Limit of exposure per position (%T)      : %v\n", par.Lim, par.Lim)
+	if len(par.Commission.Model) > 0 {
+		fmt.Printf("Commission model: %s\n", par.Commission.Model)
+	} else {
+		fmt.Printf("Fee (%T): %v\n", par.Fee, par.Fee)
+	}
+	if len(par.Slippage.Model) > 0 {
+		fmt.Printf("Slippage model: %s, %v\n", par.Slippage.Model, par.Slippage.Value)
+	}
+	if f := par.Financing; f.Borrow > 0 || f.Margin > 0 || par.Columns.Borrow > 0 {
+		fmt.Printf("Financing (%s): borrow rate %v, margin rate %v\n", f.DayCount, f.Borrow, f.Margin)
+	}
+	if len(par.Reinvest) > 0 {
+		fmt.Printf("Reinvestment of profits: %s, fraction %v\n", par.Reinvest, par.Fraction)
+	}
+	if len(par.Sizing.Model) > 0 {
+		fmt.Printf("Position sizing: %s, %v\n", par.Sizing.Model, par.Sizing.Value)
+	}
+	if par.RoundShares {
+		fmt.Printf("Whole lots of shares: %v (1 by default)\n", par.LotSize)
+	}
+	if s := par.Stops; s.Loss > 0 || s.Profit > 0 || s.Trail > 0 {
+		fmt.Printf("Stops (%s, %s): stop-loss %v, take-profit %v, trailing %v\n", s.Scope, s.Unit, s.Loss, s.Profit, s.Trail)
+	}
+	if len(par.SignalMode) > 0 {
+		fmt.Printf("Signal mode: %s\n", par.SignalMode)
+	}
+	if par.Delay > 0 {
+		fmt.Printf("Execution delay (bars): %v\n", par.Delay)
+	}
+	if len(par.DateLayout) > 0 {
+		fmt.Printf("Date layout: %s\n", par.DateLayout)
+	}
+	if par.WashSale {
+		fmt.Printf("Wash-sale window (days): %v (30 by default)\n", par.WashWindow)
+	}
+	if par.NoLoss {
+		fmt.Printf("No-loss exits, blocked for at most %v bars (0 for no limit)\n", par.MaxBlock)
+	}
 }
 
 // warning prints an error message. It does not cause the process to end.
@@ -7,10 +7,53 @@
 // given that returns are not reinvested and positions are not rebalanced.
 package fifo
 
-// mtm for market values of the asset
-func (this *Asset) mtm() {
-	this.S.Val = this.S.Qty.E * this.Pxs.Cl
-	this.L.Val = this.L.Qty.E * this.Pxs.Cl
+import (
+	"fmt"
+	"strings"
+)
+
+// Marking of positions to market
+const (
+	// At the close price
+	markClose  string = "close"
+	// At the mid price
+	markMid    string = "mid"
+	// At the price the position would be closed at: the ask for short, the 
+	// bid for long positions
+	markBidAsk string = "bidask"
+)
+
+// markMode checks the name of the marking mode; 'close' by default.
+func markMode(name string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(name)); mode {
+	case "":
+		return markClose, nil
+
+	case markClose, markMid, markBidAsk:
+		return mode, nil
+
+	default:
+		return "", fmt.Errorf("unknown marking mode '%s'", name)
+	}
+}
+
+// mtm for market values of the asset. Positions are marked at the close 
+// price if there are no quotes on the bar.
+func (this *Asset) mtm(mark string) {
+	switch {
+	case mark == markMid && this.Pxs.quoted():
+		mid := (this.Pxs.Bid + this.Pxs.Ask) / 2
+		this.S.Val = this.S.Qty.E * mid
+		this.L.Val = this.L.Qty.E * mid
+
+	case mark == markBidAsk && this.Pxs.quoted():
+		this.S.Val = this.S.Qty.E * this.Pxs.Ask
+		this.L.Val = this.L.Qty.E * this.Pxs.Bid
+
+	default:
+		this.S.Val = this.S.Qty.E * this.Pxs.Cl
+		this.L.Val = this.L.Qty.E * this.Pxs.Cl
+	}
 }
 
 // returns for total Net Returns, realized and unrealized
@@ -22,9 +65,12 @@ func (this *Asset) returns(prev Asset) {
 	this.S.Result.Tot = this.S.Result.Rzd + this.S.Result.Unr
 	this.L.Result.Tot = this.L.Result.Rzd + this.L.Result.Unr
 
-	// Cumulative sum of returns
+	// Cumulative sum of returns, dividends included, borrow fees and margin
+	// interest deducted
 	this.CumReturn += (this.S.Result.Rzd + this.L.Result.Rzd +
-		this.S.Result.UnrChg + this.L.Result.UnrChg)
+		this.S.Result.UnrChg + this.L.Result.UnrChg +
+		this.S.Div + this.L.Div -
+		this.Borrow - this.Interest)
 }
 
 // unrealized for the Unrealized Returns
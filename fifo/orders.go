@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// deltaMode tells whether the signal mode, 'target' (default) or 'delta',
+// takes the signals for order quantities rather than target positions.
+func deltaMode(name string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "target":
+		return false, nil
+
+	case "delta":
+		return true, nil
+
+	default:
+		return false, fmt.Errorf("unknown signal mode '%s'", name)
+	}
+}
+
+// orders converts the order quantities of the bar into target positions by
+// applying them to the positions held at the end of the previous bar: a buy
+// (positive) adds to the long position or covers the short one, a sell
+// (negative) reduces the long position or adds to the short one. A sell
+// exceeding the long position flips it over to a short one, and vice versa.
+// In the hedged mode, the short and the long books take their own orders and
+// never flip over, so that an order exceeding the book only closes it.
+// Note: Orders are not carried over; the part of an order not filled, e.g.
+// a blocked exit or a rejected entry, is dropped.
+func orders(bar Bar, prev Asset, q argsFIFO) Bar {
+	if !q.Delta {
+		return bar
+	}
+
+	if q.Hedged {
+		// Note: Sign convention. Short positions are negative in the input.
+		bar.Short -= prev.S.Pos.E
+		bar.Long  += prev.L.Pos.E
+		if bar.Short > 0 {
+			bar.Short = 0
+		}
+		if bar.Long < 0 {
+			bar.Long = 0
+		}
+		return bar
+	}
+
+	bar.Position += prev.L.Pos.E - prev.S.Pos.E
+	return bar
+}
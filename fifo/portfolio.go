@@ -0,0 +1,384 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Instrument is a series of bars of a single symbol traded in a portfolio.
+type Instrument struct {
+	// Symbol of the instrument
+	Symbol string
+
+	// Prices and signals
+	Bars   []Bar
+}
+
+// Book holds the results of a portfolio on a bar.
+type Book struct {
+	// Bar ID
+	Bar    string
+
+	// Results per instrument, in the order of symbols. The results of an
+	// instrument with no bar are carried over from its previous bar.
+	Assets []Asset
+
+	// Flags showing which instruments have the bar
+	Traded []bool
+
+	// Combined results of all instruments: net asset value, drawdowns,
+	// trading costs and trade counters
+	Total  Asset
+
+	// Margin interest accrued on the negative cash of the portfolio so far
+	Interest float64
+}
+
+// Portfolio holds the results of a portfolio simulation.
+type Portfolio struct {
+	// Symbols of the instruments
+	Symbols []string
+
+	// Results of simulated trading, one per bar of the combined timeline
+	Books   []Book
+
+	// The ledger of closed lots of all instruments in the order of closing
+	Ledger  []Closed
+
+	// Performance statistics of the portfolio
+	Summary Summary
+
+	// Realized results of all instruments by years, sides and holding 
+	// periods; empty if no date layout is set
+	Tax     []TaxBucket
+
+	// Wash-sale adjustments of all instruments in the order of the bars; 
+	// empty if the wash-sale mode is off
+	Wash    []WashSale
+
+	// The name of the lot-relief policy which produced the results
+	Relief  string
+
+	// Input data problems, reported by symbols
+	Problems Problems
+}
+
+// Instruments groups the bars by symbols in the order of first appearance.
+// Bars with no symbol make up an instrument under the name given.
+func Instruments(bars []Bar, name string) []Instrument {
+	var all []Instrument
+
+	index := make(map[string]int)
+	for _, one := range bars {
+		symbol := one.Symbol
+		if len(symbol) == 0 {
+			symbol = name
+		}
+		i, ok := index[symbol]
+		if !ok {
+			i = len(all)
+			index[symbol] = i
+			all = append(all, Instrument{Symbol: symbol})
+		}
+		all[i].Bars = append(all[i].Bars, one)
+	}
+	return all
+}
+
+// SimulatePortfolio runs trade result calculations on several instruments
+// trading against a single cash base. Bars of the instruments are aligned by
+// their IDs. It neither prints anything nor touches the file system.
+func SimulatePortfolio(ctx context.Context, instruments []Instrument, par Params) (Portfolio, error) {
+	var res Portfolio
+
+	if len(instruments) == 0 {
+		return res, errors.New("no instruments to simulate")
+	}
+
+	q, err := par.args()
+	if err != nil {
+		return res, err
+	}
+
+	all  := make([]Instrument, len(instruments))
+	seen := make(map[string]bool, len(instruments))
+	for i, one := range instruments {
+		if seen[one.Symbol] {
+			return res, fmt.Errorf("symbol '%s' found more than once", one.Symbol)
+		}
+		seen[one.Symbol] = true
+
+		if len(one.Bars) == 0 {
+			return res, fmt.Errorf("no bars to simulate for symbol '%s'", one.Symbol)
+		}
+		bars, problems, err := checkBars(one.Bars, par)
+		for j := range problems {
+			problems[j].File = one.Symbol
+		}
+		res.Problems = append(res.Problems, problems...)
+		if err != nil {
+			return res, err
+		}
+
+		all[i] = Instrument{Symbol: one.Symbol, Bars: delayed(bars, q.Delay)}
+		res.Symbols = append(res.Symbols, one.Symbol)
+	}
+
+	books, err := portfolio(ctx, all, q)
+	if err != nil {
+		return res, err
+	}
+
+	totals := make([]Asset, len(books))
+	for i, one := range books {
+		totals[i] = one.Total
+	}
+	closed := portfolioLedger(books, res.Symbols)
+	adjustments := portfolioWashes(books, res.Symbols)
+	washLedger(closed, adjustments)
+
+	res.Books   = books
+	res.Ledger  = closed
+	res.Wash    = adjustments
+	res.Summary = summarize(totals, closed, par.BarsPerYear, par.RiskFree)
+	res.Relief  = q.Relief.Name()
+	res.Summary.Relief = res.Relief
+	if len(par.DateLayout) > 0 {
+		res.Tax = taxReport(closed)
+	}
+	return res, nil
+}
+
+// portfolio calculates results of model trade of several instruments. New
+// entries are sized from the values of the whole portfolio as at the end of
+// the previous bar. The calculation stops if the context is cancelled.
+func portfolio(ctx context.Context, instruments []Instrument, q argsFIFO) ([]Book, error) {
+	ids, err := timeline(instruments)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		books   = make([]Book, len(ids))
+		state   = make([]Asset, len(instruments))
+		cursor  = make([]int, len(instruments))
+		started = make([]bool, len(instruments))
+	)
+	// Note: Margin interest is charged on the cash of the whole portfolio,
+	// not on the cash flows of the instruments (see total).
+	qi := q
+	qi.Finance.margin = 0
+
+	// Note: Every instrument starts with the whole cash base, so that the
+	// cash of the portfolio is the cash base changed by the cash flows of all
+	// instruments.
+	for k := range state {
+		state[k].Cash = q.Cashbase
+	}
+
+	for t, id := range ids {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		acct := q.start()
+		if t > 0 {
+			acct = books[t-1].Total.account()
+		}
+
+		book := Book{
+			Bar:    id,
+			Assets: make([]Asset, len(instruments)),
+			Traded: make([]bool, len(instruments)),
+		}
+		for k, one := range instruments {
+			if cursor[k] < len(one.Bars) && one.Bars[cursor[k]].ID == id {
+				bar    := one.Bars[cursor[k]]
+				before := state[k].free()
+
+				switch started[k] {
+				case true:
+					state[k].step(bar, state[k], acct, qi)
+
+				default:
+					state[k].first(bar, acct, qi)
+					started[k] = true
+				}
+				cursor[k] += 1
+				book.Traded[k] = true
+
+				// Note: Instruments compete for the cash free on the bar.
+				acct.Free += state[k].free() - before
+			}
+			book.Assets[k] = state[k]
+		}
+
+		var prev Book
+		if t > 0 {
+			prev = books[t-1]
+		}
+		book.total(prev, t, q)
+		books[t] = book
+	}
+	return books, nil
+}
+
+// total combines the results of the instruments. Flows on the bar, such as
+// trading costs, are counted for the instruments having the bar only. Margin
+// interest is accrued on the negative cash of the portfolio at the end of the
+// previous bar.
+func (this *Book) total(prev Book, n int, q argsFIFO) {
+	var all Asset
+
+	cashbase := q.Cashbase
+
+	all.Bar  = this.Bar
+	all.N    = n
+	all.Cash = cashbase
+	for k, one := range this.Assets {
+		all.Cash         += one.Cash - cashbase
+		all.S.Basis.E    += one.S.Basis.E
+		all.L.Basis.E    += one.L.Basis.E
+		all.CumReturn    += one.CumReturn
+		all.S.Pos.E      += one.S.Pos.E
+		all.L.Pos.E      += one.L.Pos.E
+		all.S.Result.Unr += one.S.Result.Unr
+		all.L.Result.Unr += one.L.Result.Unr
+		all.EntryN       += one.EntryN
+		all.ExitN        += one.ExitN
+
+		if !this.Traded[k] {
+			continue
+		}
+		all.S.Result.Rzd += one.S.Result.Rzd
+		all.L.Result.Rzd += one.L.Result.Rzd
+		all.S.Div        += one.S.Div
+		all.L.Div        += one.L.Div
+		all.S.Fees       += one.S.Fees
+		all.L.Fees       += one.L.Fees
+		all.S.Slip       += one.S.Slip
+		all.L.Slip       += one.L.Slip
+		all.S.Spread     += one.S.Spread
+		all.L.Spread     += one.L.Spread
+		all.Residual     += one.Residual
+		all.Borrow       += one.Borrow
+		all.Date          = one.Date
+	}
+
+	if n > 0 {
+		all.Interest = q.Finance.interest(prev.Total.Cash, q.Finance.years(prev.Total.Date, all.Date))
+	}
+	this.Interest   = prev.Interest + all.Interest
+	all.Cash       -= this.Interest
+	all.CumReturn  -= this.Interest
+
+	all.assets(cashbase)
+	all.maxAssets(prev.Total)
+	all.drawdown(cashbase)
+	all.wdd(prev.Total)
+
+	this.Total = all
+}
+
+// timeline returns the bar IDs of all instruments in the order of trading.
+// If the instruments have different bars, the IDs are sorted, so that they
+// must sort in time order (e.g. 'YYYY-MM-DD').
+func timeline(instruments []Instrument) ([]string, error) {
+	var (
+		ids  []string
+		same = true
+	)
+
+	for _, one := range instruments {
+		seen := make(map[string]bool, len(one.Bars))
+		for _, bar := range one.Bars {
+			if seen[bar.ID] {
+				return nil, fmt.Errorf("bar ID '%s' found more than once for symbol '%s'", bar.ID, one.Symbol)
+			}
+			seen[bar.ID] = true
+		}
+		same = same && sameBars(one.Bars, instruments[0].Bars)
+	}
+
+	if same {
+		ids = make([]string, len(instruments[0].Bars))
+		for i, bar := range instruments[0].Bars {
+			ids[i] = bar.ID
+		}
+		return ids, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, one := range instruments {
+		for i, bar := range one.Bars {
+			if i > 0 && one.Bars[i-1].ID >= bar.ID {
+				return nil, fmt.Errorf("bar IDs of symbol '%s' are not in ascending order: '%s' after '%s'",
+					one.Symbol, bar.ID, one.Bars[i-1].ID)
+			}
+			if !seen[bar.ID] {
+				seen[bar.ID] = true
+				ids = append(ids, bar.ID)
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// sameBars tells whether the bars have the same IDs in the same order.
+func sameBars(a, b []Bar) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+// portfolioLedger collects ledger entries of all instruments in the order of
+// closing, along with their symbols.
+func portfolioLedger(books []Book, symbols []string) []Closed {
+	var all []Closed
+	for _, book := range books {
+		for k, one := range book.Assets {
+			if !book.Traded[k] {
+				continue
+			}
+			for _, closed := range ledger([]Asset{one}) {
+				closed.Symbol = symbols[k]
+				all = append(all, closed)
+			}
+		}
+	}
+	return all
+}
+
+// portfolioWashes collects the wash-sale adjustments of all instruments in
+// the order of the bars, symbols put in.
+func portfolioWashes(books []Book, symbols []string) []WashSale {
+	var all []WashSale
+	for _, book := range books {
+		for k, one := range book.Assets {
+			if !book.Traded[k] {
+				continue
+			}
+			for _, adjustment := range washes([]Asset{one}) {
+				adjustment.Symbol = symbols[k]
+				all = append(all, adjustment)
+			}
+		}
+	}
+	return all
+}
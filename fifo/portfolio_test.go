@@ -0,0 +1,117 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// instruments returns two instruments: AAA trading on every bar, BBB missing
+// the third bar and going short on the last one.
+func instruments() []Instrument {
+	return []Instrument{
+		{
+			Symbol: "AAA",
+			Bars: dated([]string{"2020-01-02", "2020-01-03", "2020-01-06", "2020-01-07", "2020-01-08"},
+				[]float64{100, 102, 101, 99, 104}, []float64{1, 2, 2, 1, 0}),
+		},
+		{
+			Symbol: "BBB",
+			Bars: dated([]string{"2020-01-02", "2020-01-03", "2020-01-07", "2020-01-08"},
+				[]float64{50, 49, 47, 48}, []float64{1, 2, 1, -1}),
+		},
+	}
+}
+
+func TestPortfolioNAV(t *testing.T) {
+	tests := []struct {
+		name string
+		par  Params
+	}{
+		{"cash covering the entries", Params{Cash: 1e6, Lim: 10000, Fee: 0.01}},
+		{"cash overdrawn", Params{Cash: 20000, Lim: 10000, Fee: 0.01}},
+		{"margin interest", Params{Cash: 20000, Lim: 10000, Fee: 0.01, DateLayout: "2006-01-02",
+			Financing: Financing{Margin: 0.05}}},
+		{"cash policy", Params{Cash: 20000, Lim: 10000, Fee: 0.01, CashPolicy: "reject"}},
+	}
+	for _, tt := range tests {
+		res, err := SimulatePortfolio(context.Background(), instruments(), tt.par)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(res.Books) != 5 {
+			t.Fatalf("%s: %d bars, want 5", tt.name, len(res.Books))
+		}
+		if res.Books[2].Traded[1] {
+			t.Errorf("%s: BBB traded on %s", tt.name, res.Books[2].Bar)
+		}
+		if interest := res.Books[len(res.Books) - 1].Interest; (tt.par.Financing.Margin > 0) != (interest > 0) {
+			t.Errorf("%s: margin interest %v", tt.name, interest)
+		}
+
+		// NAV is the cash of the portfolio plus the values of the positions
+		// held in all instruments. The first bar is at the starting NAV.
+		if nav := res.Books[0].Total.NAV; nav != tt.par.Cash {
+			t.Errorf("%s: starting NAV %v, want %v", tt.name, nav, tt.par.Cash)
+		}
+		for _, book := range res.Books[1:] {
+			value := 0.0
+			for _, one := range book.Assets {
+				value += one.S.Val + one.L.Val
+			}
+			if math.Abs(book.Total.NAV - (book.Total.Cash + value)) > 1e-6 {
+				t.Errorf("%s: NAV %v on %s, want cash %v plus values %v", tt.name, book.Total.NAV, book.Bar, book.Total.Cash, value)
+			}
+		}
+	}
+}
+
+func TestPortfolioSingle(t *testing.T) {
+	tests := []struct {
+		name string
+		par  Params
+	}{
+		{"basic", Params{Cash: 1e6, Lim: 10000}},
+		{"fees and overdrawn cash", Params{Cash: 20000, Lim: 15000, Fee: 0.01}},
+		{"reinvested", Params{Cash: 1e5, Lim: 10000, Reinvest: "nav"}},
+		{"margin interest", Params{Cash: 20000, Lim: 15000, DateLayout: "2006-01-02",
+			Financing: Financing{Margin: 0.05}}},
+	}
+	for _, tt := range tests {
+		one := instruments()[:1]
+
+		single, err := Simulate(context.Background(), one[0].Bars, tt.par)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		port, err := SimulatePortfolio(context.Background(), one, tt.par)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(port.Books) != len(single.Assets) || len(port.Ledger) != len(single.Ledger) {
+			t.Fatalf("%s: %d bars and %d closed lots, want %d and %d", tt.name,
+				len(port.Books), len(port.Ledger), len(single.Assets), len(single.Ledger))
+		}
+
+		for i, want := range single.Assets {
+			got := port.Books[i].Total
+			if math.Abs(got.NAV - want.NAV) > 1e-6 || math.Abs(got.Cash - want.Cash) > 1e-6 ||
+				math.Abs(got.L.Result.Rzd - want.L.Result.Rzd) > 1e-6 {
+				t.Errorf("%s: NAV %v, cash %v and realized %v on %s; want %v, %v and %v", tt.name,
+					got.NAV, got.Cash, got.L.Result.Rzd, want.Bar, want.NAV, want.Cash, want.L.Result.Rzd)
+			}
+		}
+		for i, want := range single.Ledger {
+			if got := port.Ledger[i]; got.Symbol != "AAA" || math.Abs(got.Rzd - want.Rzd) > 1e-6 || got.Qty != want.Qty {
+				t.Errorf("%s: closed lot %d %+v, want %+v", tt.name, i, got, want)
+			}
+		}
+		if math.Abs(port.Summary.EndNAV - single.Summary.EndNAV) > 1e-6 || port.Summary.Trades != single.Summary.Trades {
+			t.Errorf("%s: summary %+v, want %+v", tt.name, port.Summary, single.Summary)
+		}
+	}
+}
@@ -8,25 +8,94 @@
 package fifo
 
 import (
-	"fmt"
-	"strconv"
+	"math"
 )
 
 // prices puts underlying asset's Close (last) and Trade prices into the Asset 
 // object.
-// No parsing errors are taken into account.
-func (this *Asset) prices(signals Trades) {
-	closePx, errCl := strconv.ParseFloat(signals.Cl, 64)
-	if errCl != nil {
-		msgCl := "WARNING! '" + signals.Cl + "' read as '" + fmt.Sprintf("%f", closePx) + "'"
-		warning(msgCl, errCl)
+func (this *Asset) prices(bar Bar) {
+	this.Pxs.Cl  = bar.Close
+	this.Pxs.Tx  = bar.Trade
+	this.Pxs.Op  = bar.Open
+	this.Pxs.Hi  = bar.High
+	this.Pxs.Lo  = bar.Low
+	this.Pxs.Rng = bar.High - bar.Low
+	this.Pxs.ATR = 0
+	this.Pxs.Sigma = 0
+	this.Pxs.Vol = bar.Volume
+	this.Pxs.Bid = bar.Bid
+	this.Pxs.Ask = bar.Ask
+}
+
+// barRange for the range of the bar: the true range, i.e. the high less the 
+// low extended to the previous close, if intrabar prices are known, otherwise
+// the absolute change of the close price since the previous bar (adjusted 
+// for splits).
+func (this *Asset) barRange(prev Asset) {
+	if this.Pxs.intrabar() {
+		this.Pxs.Rng = math.Max(this.Pxs.Hi, prev.Pxs.Cl) - math.Min(this.Pxs.Lo, prev.Pxs.Cl)
+		return
 	}
-	this.Pxs.Cl = closePx
+	this.Pxs.Rng = math.Abs(this.Pxs.Cl - prev.Pxs.Cl)
+}
+
+// intrabar tells whether open, high and low prices are known.
+func (this Prices) intrabar() bool {
+	return this.Hi > 0 && this.Lo > 0
+}
+
+// open returns the opening price of the bar, or the trade price if intrabar 
+// prices are unknown.
+func (this Prices) open() float64 {
+	if this.intrabar() {
+		return this.Op
+	}
+	return this.Tx
+}
+
+// high returns the highest price of the bar, or the higher one of the trade 
+// and the close prices if intrabar prices are unknown.
+func (this Prices) high() float64 {
+	if this.intrabar() {
+		return this.Hi
+	}
+	return math.Max(this.Tx, this.Cl)
+}
+
+// low returns the lowest price of the bar, or the lower one of the trade and
+// the close prices if intrabar prices are unknown.
+func (this Prices) low() float64 {
+	if this.intrabar() {
+		return this.Lo
+	}
+	return math.Min(this.Tx, this.Cl)
+}
+
+// quoted tells whether bid and ask prices are known.
+func (this Prices) quoted() bool {
+	return this.Bid > 0 && this.Ask > 0
+}
+
+// quote returns the price an order is filled at before slippage: the ask for
+// buys and the bid for sells, or the trade price if there are no quotes.
+func (this Prices) quote(sell bool) float64 {
+	switch {
+	case !this.quoted():
+		return this.Tx
+
+	case sell:
+		return this.Bid
+
+	default:
+		return this.Ask
+	}
+}
 
-	tradePx, errTx := strconv.ParseFloat(signals.Tx, 64)
-	if errTx != nil {
-		msgTx := "WARNING! '" + signals.Tx + "' read as '" + fmt.Sprintf("%f", tradePx) + "'"
-		warning(msgTx, errTx)
+// spread returns the spread cost per share, i.e. the distance from the quote
+// to the mid price; 0 if there are no quotes.
+func (this Prices) spread() float64 {
+	if !this.quoted() {
+		return 0
 	}
-	this.Pxs.Tx = tradePx
+	return (this.Ask - this.Bid) / 2
 }
@@ -12,22 +12,56 @@ import (
 )
 
 // cfNew for net proceeds, or net cash flow, from new trades, opening new positions
-func (this *Asset) cfNew(fee float64) {
+func (this *Asset) cfNew() {
 	// Note: SHORT ==> positive proceeds; selling to open short position;
 	// price received, fees subtracted
-	this.S.NetCF.I = +math.Abs(this.S.Qty.I) * (this.Pxs.Tx - fee)
+	this.S.NetCF.I = +math.Abs(this.S.Qty.I) * (this.S.PxI - this.S.FeeI)
 	// Note: LONG ===> negative proceeds; buying to open long position;
 	// price paid, fees added
-	this.L.NetCF.I = -math.Abs(this.L.Qty.I) * (this.Pxs.Tx + fee)
+	this.L.NetCF.I = -math.Abs(this.L.Qty.I) * (this.L.PxI + this.L.FeeI)
 }
 
 // cfRemov for net proceeds, or net cash flow, from position removal, closing 
-// positions
-func (this *Asset) cfRemov(fee float64) {
+// positions, protective exits and fractional shares cashed out included
+func (this *Asset) cfRemov() {
 	// Note: SHORT ==> negative proceeds; buying to close short position;
 	// price paid, fee added
-	this.S.NetCF.O = -math.Abs(this.S.Qty.O) * (this.Pxs.Tx + fee)
+	this.S.NetCF.O = -this.S.exitQty() * (this.S.PxO + this.S.FeeO) + this.S.Hit.CF + this.S.Lieu.CF
 	// Note: LONG ==> positive proceeds; selling to close long position;
 	// price received, fees subtracted
-	this.L.NetCF.O = +math.Abs(this.L.Qty.O) * (this.Pxs.Tx - fee)
+	this.L.NetCF.O = +this.L.exitQty() * (this.L.PxO - this.L.FeeO) + this.L.Hit.CF + this.L.Lieu.CF
+}
+
+// exitQty returns the quantity of the signal exit order, i.e. the quantity
+// removed less the protective exits and the fractional shares cashed out.
+func (this Position) exitQty() float64 {
+	return math.Abs(this.Qty.O) - math.Abs(this.Hit.Qty) - math.Abs(this.Lieu.Qty)
+}
+
+// costs for commissions, slippage and spread costs paid on the bar, entries 
+// and exits
+func (this *Asset) costs() {
+	// Note: Selling to open short position, buying to open long position.
+	this.S.costs(this.Pxs, true)
+	this.L.costs(this.Pxs, false)
+}
+
+// costs for commissions, slippage and spread costs paid on the bar by one 
+// side. The entry order is a sale for the short side.
+// Note: Protective exits are filled at their levels, so that they bear no 
+// spread cost.
+func (this *Position) costs(pxs Prices, sell bool) {
+	qtyI, qtyO := math.Abs(this.Qty.I), this.exitQty()
+
+	this.Fees   = qtyI * this.FeeI + qtyO * this.FeeO + this.Hit.Fees
+	this.Slip   = this.Hit.Slip
+	this.Spread = 0
+	if qtyI > 0 {
+		this.Slip   += qtyI * math.Abs(this.PxI - pxs.quote(sell))
+		this.Spread += qtyI * pxs.spread()
+	}
+	if qtyO > 0 {
+		this.Slip   += qtyO * math.Abs(this.PxO - pxs.quote(!sell))
+		this.Spread += qtyO * pxs.spread()
+	}
 }
@@ -11,11 +11,71 @@ import (
 	"math"
 )
 
-// qtyNew for the added quantity, new trades.
-// Note: Fees should be taken into account, so that cash is not overspent.
-func (this *Asset) qtyNew(lot, fee float64) {
-	this.S.Qty.I = -math.Abs(float64(this.S.Pos.I)) * lot / (this.Pxs.Tx - fee)
-	this.L.Qty.I = +math.Abs(float64(this.L.Pos.I)) * lot / (this.Pxs.Tx + fee)
+// qtyNew for the added quantity, new trades, along with the fill prices and 
+// the fees per share of the entry orders. It returns the exposure per position
+// of the short and the long entries. In the whole-share mode, quantities are 
+// rounded down to whole lots of shares, and the exposure left unspent is the
+// residual.
+// Note: Fees and slippage should be taken into account, so that cash is not 
+// overspent.
+func (this *Asset) qtyNew(lot float64, q argsFIFO) (float64, float64) {
+	shares, fixed := q.Sizing.(fixedShares)
+
+	// Note: Selling to open short position, buying to open long position.
+	switch {
+	case fixed:
+		// A fixed number of shares per position, scaled down along with the
+		// exposure by the cash policy, if need be
+		n := shares.Shares * ratio(lot, shares.Exposure(0, 0, this.Pxs))
+		this.S.Qty.I = -n * math.Abs(this.S.Pos.I)
+		this.L.Qty.I = +n * math.Abs(this.L.Pos.I)
+
+	default:
+		this.S.Qty.I = -quantity(q.Fees, q.Slip, math.Abs(this.S.Pos.I) * lot, this.Pxs, true)
+		this.L.Qty.I = +quantity(q.Fees, q.Slip, math.Abs(this.L.Pos.I) * lot, this.Pxs, false)
+	}
+
+	// The exposure per position of the quantities
+	lotS, lotL := lot, lot
+	if fixed {
+		// The exposure of a fixed number of shares is the net amount of the
+		// order
+		lotS, lotL = this.entryLots(q)
+	}
+
+	this.Residual = 0
+	if q.Round > 0 {
+		this.S.Qty.I = wholeLots(this.S.Qty.I, q.Round)
+		this.L.Qty.I = wholeLots(this.L.Qty.I, q.Round)
+
+		roundS, roundL := this.entryLots(q)
+		this.Residual = (lotS - roundS) * math.Abs(this.S.Pos.I) +
+			(lotL - roundL) * math.Abs(this.L.Pos.I)
+		lotS, lotL = roundS, roundL
+	}
+
+	this.S.PxI = fill(q.Slip, math.Abs(this.S.Qty.I), this.Pxs, true)
+	this.L.PxI = fill(q.Slip, math.Abs(this.L.Qty.I), this.Pxs, false)
+
+	this.S.FeeI = q.Fees.Fee(math.Abs(this.S.Qty.I), this.S.PxI, true)
+	this.L.FeeI = q.Fees.Fee(math.Abs(this.L.Qty.I), this.L.PxI, false)
+
+	return lotS, lotL
+}
+
+// entryLots returns the exposure per position of the short and the long 
+// entries as the net amounts of the entry orders, i.e. the quantities at the 
+// fill prices, fees accounted.
+func (this *Asset) entryLots(q argsFIFO) (float64, float64) {
+	sell := math.Abs(this.S.Qty.I)
+	buy  := math.Abs(this.L.Qty.I)
+
+	pxS := fill(q.Slip, sell, this.Pxs, true)
+	pxL := fill(q.Slip, buy, this.Pxs, false)
+
+	lotS := ratio(sell * (pxS - q.Fees.Fee(sell, pxS, true)), math.Abs(this.S.Pos.I))
+	lotL := ratio(buy * (pxL + q.Fees.Fee(buy, pxL, false)), math.Abs(this.L.Pos.I))
+	return lotS, lotL
 }
 
 // qtyStart for the starting quantity
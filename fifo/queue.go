@@ -7,15 +7,81 @@
 // given that returns are not reinvested and positions are not rebalanced.
 package fifo
 
+import (
+	"time"
+)
+
+// Pending is a lot of positions opened on the same bar and not closed yet.
+// Quantities and values are totals for the lot, not per unit of size.
 type Pending struct {
+	// Bar ID of the entry
+	Bar   string
+
+	// Bar number of the entry (zero-based)
+	N     int
+
+	// The date the lot was acquired, i.e. the date of the entry bar; zero if
+	// no date layout is set
+	Acquired time.Time
+
+	// The size of the lot, i.e. the number of positions in it
+	Size  float64
+
 	// The number of stocks once opened and not closed yet
 	Qty   float64
 
-	// The cost of a position once opened and not closed yet
+	// The net cost price of a position once opened and not closed yet
 	Cost  float64
 
 	// The basis of a position once opened and not closed yet
 	Basis float64
+
+	// Fees per share paid at the entry
+	Fee   float64
+
+	// Slippage per share at the entry
+	Slip  float64
+
+	// The best price since the entry, e.g. for trailing stops: the highest 
+	// for long lots, the lowest for short lots
+	Peak  float64
+
+	// The worst price since the entry
+	Worst float64
+
+	// Average true range as at the entry
+	ATR   float64
+
+	// The share of the lot's shares replacing shares sold at a loss in the
+	// wash-sale mode, 0 to 1
+	Washed float64
+
+	// The losses disallowed by the wash-sale rule and added to the tax basis
+	// of the lot; the basis itself is left as is
+	WashAdj float64
+}
+
+// cut splits up the lot into two parts: (1) a part of (at most) n positions
+// being removed and (2) the rest of the lot. The quantity and the basis are
+// shared pro rata.
+func (lot Pending) cut(n float64) (Pending, Pending) {
+	removed, rest := lot, lot
+	if n >= lot.Size {
+		rest.Size, rest.Qty, rest.Basis, rest.WashAdj = 0, 0, 0, 0
+		return removed, rest
+	}
+	share := n / lot.Size
+
+	removed.Size  = n
+	removed.Qty   = lot.Qty * share
+	removed.Basis = lot.Basis * share
+	removed.WashAdj = lot.WashAdj * share
+
+	rest.Size  = roundSize(lot.Size - n)
+	rest.Qty   = lot.Qty - removed.Qty
+	rest.Basis = lot.Basis - removed.Basis
+	rest.WashAdj = lot.WashAdj - removed.WashAdj
+	return removed, rest
 }
 
 // queueAdd adds elements to the queue of quantities and basis values of  
@@ -28,7 +94,9 @@ func queueAdd(queue, addElem []Pending) []Pending {
 		// Add elements to the queue.
 		// Note: Likely, full control over the way the slice is grown 
 		// is not needed, so a built-in append function is good enough.
-		return append(queue, addElem...)
+		// The capacity is capped, so that appending never overwrites lots 
+		// shared with the queue of the previous bar (e.g. after LIFO relief).
+		return append(queue[:len(queue):len(queue)], addElem...)
 
 	default:
 		// Do nothing
@@ -39,11 +107,44 @@ func queueAdd(queue, addElem []Pending) []Pending {
 // split splits up the queue of Pending objects into two slices: 
 // (1) a slice of objects being removed from the queue and 
 // (2) a slice of objects remaining in the queue.
-func split(queue []Pending, n int) ([]Pending, []Pending) {
-	var removed []Pending
-	if n > 0 {
-		removed = queue[:n]
-		queue   = queue[n:]
+// The lots are visited from the head of the queue, and the last visited lot
+// may be relieved partially.
+func split(queue []Pending, n float64) ([]Pending, []Pending) {
+	order := make([]int, len(queue))
+	for i := range order {
+		order[i] = i
+	}
+	return take(queue, n, order)
+}
+
+// take removes n positions from the lots of the queue visited in the given
+// order. The lots remaining in the queue keep their original order.
+func take(queue []Pending, n float64, order []int) ([]Pending, []Pending) {
+	var (
+		removed, remaining []Pending
+		one                Pending
+	)
+	if n <= 0 {
+		return removed, queue
+	}
+
+	left := make([]Pending, len(queue))
+	copy(left, queue)
+
+	for _, k := range order {
+		if n <= 0 {
+			break
+		}
+		one, left[k] = left[k].cut(n)
+		n = roundSize(n - one.Size)
+		removed = append(removed, one)
+	}
+
+	remaining = make([]Pending, 0, len(left))
+	for _, one := range left {
+		if one.Size > 0 {
+			remaining = append(remaining, one)
+		}
 	}
-	return removed, queue
+	return removed, remaining
 }
@@ -0,0 +1,149 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Relief is a lot-relief policy. It decides which pending lots are closed
+// when a position declines in size.
+type Relief interface {
+	// Name returns the config name of the policy.
+	Name() string
+
+	// Relieve splits up the queue of Pending objects into two slices:
+	// (1) a slice of lots (or parts of lots) of the total size n being 
+	// removed from the queue and (2) a slice of lots remaining in the queue.
+	Relieve(queue []Pending, n float64) (removed, remaining []Pending)
+}
+
+// NewRelief returns the lot-relief policy by its config name: 'fifo',
+// 'lifo', 'hifo', 'lofo' or 'average'. An empty name stands for 'fifo'.
+func NewRelief(name string) (Relief, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "fifo":
+		return fifoRelief{}, nil
+
+	case "lifo":
+		return lifoRelief{}, nil
+
+	case "hifo":
+		return costRelief{highest: true}, nil
+
+	case "lofo":
+		return costRelief{highest: false}, nil
+
+	case "average":
+		return averageRelief{}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown lot relief policy '%s'", name)
+	}
+}
+
+// fifoRelief closes the oldest lots first (First-In-First-Out).
+type fifoRelief struct{}
+
+func (fifoRelief) Name() string { return "fifo" }
+
+func (fifoRelief) Relieve(queue []Pending, n float64) ([]Pending, []Pending) {
+	return split(queue, n)
+}
+
+// lifoRelief closes the newest lots first (Last-In-First-Out).
+type lifoRelief struct{}
+
+func (lifoRelief) Name() string { return "lifo" }
+
+func (lifoRelief) Relieve(queue []Pending, n float64) ([]Pending, []Pending) {
+	order := make([]int, len(queue))
+	for i := range order {
+		order[i] = len(queue) - 1 - i
+	}
+	return take(queue, n, order)
+}
+
+// costRelief closes the lots with the highest (HIFO) or the lowest (LOFO)
+// net cost price first. Lots of equal cost are closed in the FIFO order.
+// Note: The net cost price is compared as is on both sides, i.e. HIFO closes
+// the short lots sold at the highest net price first.
+type costRelief struct {
+	highest bool
+}
+
+func (this costRelief) Name() string {
+	if this.highest {
+		return "hifo"
+	}
+	return "lofo"
+}
+
+func (this costRelief) Relieve(queue []Pending, n float64) ([]Pending, []Pending) {
+	order := make([]int, len(queue))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		if this.highest {
+			return queue[order[a]].Cost > queue[order[b]].Cost
+		}
+		return queue[order[a]].Cost < queue[order[b]].Cost
+	})
+	return take(queue, n, order)
+}
+
+// averageRelief pools all lots into a single lot at the average cost before 
+// closing any part of it.
+// Note: The remaining positions are left pooled as well. The pooled lot is 
+// dated by the entry of the oldest lot and keeps the best and the worst 
+// prices of all lots.
+type averageRelief struct{}
+
+func (averageRelief) Name() string { return "average" }
+
+func (averageRelief) Relieve(queue []Pending, n float64) ([]Pending, []Pending) {
+	var (
+		pool   Pending
+		fees   float64
+		slip   float64
+		atr    float64
+		washed float64
+	)
+	if n <= 0 || len(queue) == 0 {
+		return nil, queue
+	}
+
+	pool.Bar   = queue[0].Bar
+	pool.N     = queue[0].N
+	pool.Acquired = queue[0].Acquired
+	pool.Peak  = queue[0].Peak
+	pool.Worst = queue[0].Worst
+	for _, one := range queue {
+		pool.Size  = roundSize(pool.Size + one.Size)
+		pool.Qty   += one.Qty
+		pool.Basis += one.Basis
+		pool.WashAdj += one.WashAdj
+		fees       += one.Fee * one.Qty
+		slip       += one.Slip * one.Qty
+		atr        += one.ATR * one.Qty
+		washed     += one.Washed * one.Qty
+		pool.Peak  = better(one.Qty, pool.Peak, one.Peak)
+		pool.Worst = better(-one.Qty, pool.Worst, one.Worst)
+	}
+	// Note: Sign convention. All costs are positive.
+	pool.Cost = pool.Basis / pool.Qty
+	pool.Fee  = fees / pool.Qty
+	pool.Slip = slip / pool.Qty
+	pool.ATR  = atr / pool.Qty
+	pool.Washed = washed / pool.Qty
+
+	return split([]Pending{pool}, n)
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"math"
+	"testing"
+)
+
+// lots returns a queue of lots entered on bars 1, 2, ... at the cost prices,
+// 100 shares per position.
+func lots(sizes, costs []float64) []Pending {
+	queue := make([]Pending, len(sizes))
+	for i := range sizes {
+		qty := sizes[i] * 100
+		queue[i] = Pending{N: i + 1, Size: sizes[i], Qty: qty, Cost: costs[i], Basis: qty * costs[i]}
+	}
+	return queue
+}
+
+// sizesOf returns the entry bars and the sizes of the lots.
+func sizesOf(queue []Pending) ([]int, []float64) {
+	var (
+		bars  []int
+		sizes []float64
+	)
+	for _, one := range queue {
+		bars  = append(bars, one.N)
+		sizes = append(sizes, one.Size)
+	}
+	return bars, sizes
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalSizes(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i] - b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRelieve(t *testing.T) {
+	queue := lots([]float64{1, 2, 1}, []float64{10, 30, 20})
+
+	tests := []struct {
+		policy       string
+		n            float64
+		removedBars  []int
+		removedSizes []float64
+		leftBars     []int
+		leftSizes    []float64
+	}{
+		{"fifo", 2, []int{1, 2}, []float64{1, 1}, []int{2, 3}, []float64{1, 1}},
+		{"lifo", 2, []int{3, 2}, []float64{1, 1}, []int{1, 2}, []float64{1, 1}},
+		{"hifo", 2, []int{2}, []float64{2}, []int{1, 3}, []float64{1, 1}},
+		{"lofo", 2, []int{1, 3}, []float64{1, 1}, []int{2}, []float64{2}},
+		{"average", 2, []int{1}, []float64{2}, []int{1}, []float64{2}},
+		{"fifo", 0, nil, nil, []int{1, 2, 3}, []float64{1, 2, 1}},
+		{"fifo", 4, []int{1, 2, 3}, []float64{1, 2, 1}, nil, nil},
+		{"fifo", 5, []int{1, 2, 3}, []float64{1, 2, 1}, nil, nil},
+		{"fifo", 1.5, []int{1, 2}, []float64{1, 0.5}, []int{2, 3}, []float64{1.5, 1}},
+		{"lifo", 0.25, []int{3}, []float64{0.25}, []int{1, 2, 3}, []float64{1, 2, 0.75}},
+		{"hifo", 2.1, []int{2, 3}, []float64{2, 0.1}, []int{1, 3}, []float64{1, 0.9}},
+	}
+	for _, tt := range tests {
+		policy, err := NewRelief(tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		removed, left := policy.Relieve(queue, tt.n)
+
+		bars, sizes := sizesOf(removed)
+		if !equalInts(bars, tt.removedBars) || !equalSizes(sizes, tt.removedSizes) {
+			t.Errorf("%s %v: removed lots %v of sizes %v, want %v of %v", tt.policy, tt.n, bars, sizes, tt.removedBars, tt.removedSizes)
+		}
+		bars, sizes = sizesOf(left)
+		if !equalInts(bars, tt.leftBars) || !equalSizes(sizes, tt.leftSizes) {
+			t.Errorf("%s %v: lots left %v of sizes %v, want %v of %v", tt.policy, tt.n, bars, sizes, tt.leftBars, tt.leftSizes)
+		}
+
+		// Quantities and the basis are neither lost nor made up
+		var qty, basis float64
+		for _, one := range append(removed, left...) {
+			qty   += one.Qty
+			basis += one.Basis
+		}
+		if math.Abs(qty - 400) > 1e-6 || math.Abs(basis - 9000) > 1e-6 {
+			t.Errorf("%s %v: quantity %v and basis %v, want 400 and 9000", tt.policy, tt.n, qty, basis)
+		}
+	}
+	// The queue of the previous bar is left intact
+	if bars, sizes := sizesOf(queue); !equalInts(bars, []int{1, 2, 3}) || !equalSizes(sizes, []float64{1, 2, 1}) {
+		t.Errorf("queue changed to lots %v of sizes %v", bars, sizes)
+	}
+}
+
+func TestCut(t *testing.T) {
+	lot := Pending{Size: 1.5, Qty: 300, Cost: 20, Basis: 6000, WashAdj: 30}
+
+	tests := []struct {
+		n                    float64
+		removedSize, restSize float64
+		removedQty, restQty  float64
+	}{
+		{0.5, 0.5, 1, 100, 200},
+		{1, 1, 0.5, 200, 100},
+		{0.1, 0.1, 1.4, 20, 280},
+		{1.5, 1.5, 0, 300, 0},
+		{2, 1.5, 0, 300, 0},
+	}
+	for _, tt := range tests {
+		removed, rest := lot.cut(tt.n)
+		if !equalSizes([]float64{removed.Size, rest.Size, removed.Qty, rest.Qty},
+			[]float64{tt.removedSize, tt.restSize, tt.removedQty, tt.restQty}) {
+			t.Errorf("cut(%v) = sizes %v and %v, quantities %v and %v; want %v and %v, %v and %v", tt.n,
+				removed.Size, rest.Size, removed.Qty, rest.Qty, tt.removedSize, tt.restSize, tt.removedQty, tt.restQty)
+		}
+		// The basis and the wash-sale adjustment are shared pro rata
+		share := tt.removedQty / 300
+		if math.Abs(removed.Basis - 6000 * share) > 1e-6 || math.Abs(removed.Basis + rest.Basis - 6000) > 1e-6 {
+			t.Errorf("cut(%v): basis %v and %v", tt.n, removed.Basis, rest.Basis)
+		}
+		if math.Abs(removed.WashAdj - 30 * share) > 1e-9 || math.Abs(removed.WashAdj + rest.WashAdj - 30) > 1e-9 {
+			t.Errorf("cut(%v): wash-sale adjustments %v and %v", tt.n, removed.WashAdj, rest.WashAdj)
+		}
+		if removed.Cost != lot.Cost {
+			t.Errorf("cut(%v): cost price %v, want %v", tt.n, removed.Cost, lot.Cost)
+		}
+	}
+}
+
+func TestRoundSize(t *testing.T) {
+	tests := []struct {
+		x, want float64
+	}{
+		{0.1 + 0.2, 0.3},
+		{1 - 0.9, 0.1},
+		{2.5, 2.5},
+		{1.0000000004, 1},
+		{-0.30000000000000004, -0.3},
+	}
+	for _, tt := range tests {
+		if got := roundSize(tt.x); got != tt.want {
+			t.Errorf("roundSize(%v) = %v, want %v", tt.x, got, tt.want)
+		}
+	}
+}
@@ -7,42 +7,80 @@
 // given that returns are not reinvested and positions are not rebalanced.
 package fifo
 
-// removals for the quantity and the basis of closed position(s)
-func (this *Asset) removals(prev Asset) {
+import (
+	"math"
+)
+
+// removals for the quantity and the basis of closed position(s).
+// The lots to be closed are picked by the lot-relief policy. All lots closed
+// on a side by the signal make up a single exit order, which follows the 
+// protective exits, if any. In the whole-share mode, the lots relieved in part
+// are closed by whole lots.
+func (this *Asset) removals(prev Asset, q argsFIFO) {
 	var (
 		sh, ln []Pending
 		qtyS, qtyL float64
 		basS, basL float64
 	)
+	this.S.Queue   = prev.S.Queue
 	this.S.Qty.O   = 0
 	this.S.Basis.O = 0
+	this.S.PxO     = 0
+	this.S.FeeO    = 0
+	this.S.Closed  = nil
 
+	this.L.Queue   = prev.L.Queue
 	this.L.Qty.O   = 0
 	this.L.Basis.O = 0
+	this.L.PxO     = 0
+	this.L.FeeO    = 0
+	this.L.Closed  = nil
 
 	// Remove elements from the queue of pending positions.
-	switch {
-	case this.S.Pos.O != 0:
-		sh, this.S.Queue = split(prev.S.Queue, this.S.Pos.O)
+	// Note: Both sides may decline on the same bar in the hedged mode.
+	if this.S.Pos.O != 0 {
+		sh, this.S.Queue = q.Relief.Relieve(this.S.Queue, roundSize(this.S.Pos.O - this.S.Hit.Size))
+		if q.Round > 0 {
+			sh, this.S.Queue = wholeExits(sh, this.S.Queue, q.Round)
+		}
 
 		for i := range sh {
-			qtyS += prev.S.Queue[i].Qty
-			basS += prev.S.Queue[i].Basis
+			qtyS += sh[i].Qty
+			basS += sh[i].Basis
 		}
-		this.S.Qty.O   = -qtyS
-		this.S.Basis.O = -basS
+		// Note: Buying to close short position.
+		this.S.PxO     = fill(q.Slip, math.Abs(qtyS), this.Pxs, false)
+		this.S.FeeO    = q.Fees.Fee(math.Abs(qtyS), this.S.PxO, false)
+		this.S.Closed  = append(this.S.Hit.Closed, this.closures(sh, short, this.S.PxO, this.S.FeeO,
+			math.Abs(this.S.PxO - this.Pxs.quote(false)))...)
+		this.S.Qty.O   = -(qtyS + this.S.Hit.Qty)
+		this.S.Basis.O = -(basS + this.S.Hit.Basis)
+	}
 
-	case this.L.Pos.O != 0:
-		ln, this.L.Queue = split(prev.L.Queue, this.L.Pos.O)
+	if this.L.Pos.O != 0 {
+		ln, this.L.Queue = q.Relief.Relieve(this.L.Queue, roundSize(this.L.Pos.O - this.L.Hit.Size))
+		if q.Round > 0 {
+			ln, this.L.Queue = wholeExits(ln, this.L.Queue, q.Round)
+		}
 
 		for j := range ln {
-			qtyL += prev.L.Queue[j].Qty
-			basL += prev.L.Queue[j].Basis
+			qtyL += ln[j].Qty
+			basL += ln[j].Basis
 		}
-		this.L.Qty.O   = -qtyL
-		this.L.Basis.O = -basL
-
-	default:
-		// Do nothing
+		// Note: Selling to close long position.
+		this.L.PxO     = fill(q.Slip, math.Abs(qtyL), this.Pxs, true)
+		this.L.FeeO    = q.Fees.Fee(math.Abs(qtyL), this.L.PxO, true)
+		this.L.Closed  = append(this.L.Hit.Closed, this.closures(ln, long, this.L.PxO, this.L.FeeO,
+			math.Abs(this.L.PxO - this.Pxs.quote(true)))...)
+		this.L.Qty.O   = -(qtyL + this.L.Hit.Qty)
+		this.L.Basis.O = -(basL + this.L.Hit.Basis)
 	}
+
+	// Fractional shares cashed out
+	this.S.cashOut()
+	this.L.cashOut()
+
+	// Closed lots are numbered
+	this.S.number()
+	this.L.number()
 }
@@ -0,0 +1,161 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"math"
+)
+
+// The kind of exit of fractional shares cashed out on a split
+const exitLieu string = "lieu"
+
+// roundLot returns the lot size the quantities of new entries are rounded
+// down to: 1 share by default; 0 if quantities are not rounded.
+func roundLot(round bool, size float64) (float64, error) {
+	if size < 0 {
+		return 0, fmt.Errorf("lot size %v is negative", size)
+	}
+	switch {
+	case !round:
+		return 0, nil
+
+	case size == 0:
+		return 1, nil
+
+	default:
+		return size, nil
+	}
+}
+
+// wholeLots rounds the quantity (signed) down to whole lots of the size,
+// towards zero.
+// Note: A quantity short of a whole lot by rounding errors only is taken for
+// the whole lot.
+func wholeLots(qty, size float64) float64 {
+	lots := math.Abs(qty) / size
+	if whole := math.Round(lots); math.Abs(lots - whole) < 1e-9 {
+		lots = whole
+	}
+	return math.Copysign(math.Floor(lots) * size, qty)
+}
+
+// cashInLieu cashes out the fractional shares of the lots held, left by a
+// split on the bar, at the close price of the previous bar (adjusted for the
+// split). It returns a copy of the previous bar with whole shares in the
+// lots.
+func (this *Asset) cashInLieu(bar Bar, prev Asset, q argsFIFO) Asset {
+	this.S.Lieu = Triggered{}
+	this.L.Lieu = Triggered{}
+
+	if q.Round == 0 || splitRatio(bar.Split) == 1 {
+		return prev
+	}
+	this.S.Lieu, prev.S.Queue = this.fractions(prev.S.Queue, short, prev.Pxs.Cl)
+	this.L.Lieu, prev.L.Queue = this.fractions(prev.L.Queue, long, prev.Pxs.Cl)
+	return prev
+}
+
+// wholeExits rounds the quantities of the lots relieved in part down to whole
+// lots of the size, the fractions being left in the rest of the lots in the
+// queue. Lots rounded down to no shares are not closed.
+// Note: The number of positions removed is left as is.
+func wholeExits(removed, remaining []Pending, size float64) ([]Pending, []Pending) {
+	var exits []Pending
+
+	rest := make([]Pending, len(remaining))
+	copy(rest, remaining)
+
+	for _, one := range removed {
+		k := -1
+		for i := range rest {
+			if rest[i].N == one.N {
+				k = i
+				break
+			}
+		}
+		whole := wholeLots(one.Qty, size)
+		if k < 0 || math.Abs(one.Qty - whole) < 1e-9 {
+			exits = append(exits, one)
+			continue
+		}
+		share := (one.Qty - whole) / one.Qty
+		basis, adj := one.Basis * share, one.WashAdj * share
+
+		rest[k].Qty     += one.Qty - whole
+		rest[k].Basis   += basis
+		rest[k].WashAdj += adj
+
+		one.Qty      = whole
+		one.Basis   -= basis
+		one.WashAdj -= adj
+		if whole != 0 {
+			exits = append(exits, one)
+		}
+	}
+	return exits, rest
+}
+
+// fractions removes the fractional shares from the lots of the queue and
+// closes them at the price. It returns the fractions closed and the lots
+// remaining in the queue.
+// Note: The number of positions in a lot is left as is.
+func (this *Asset) fractions(queue []Pending, side string, px float64) (Triggered, []Pending) {
+	var (
+		cut  Triggered
+		lots []Pending
+	)
+	remaining := make([]Pending, len(queue))
+	for i, one := range queue {
+		whole := wholeLots(one.Qty, 1)
+		if math.Abs(one.Qty - whole) < 1e-9 {
+			remaining[i] = one
+			continue
+		}
+		frac := one
+		frac.Size  = 0
+		frac.Qty   = one.Qty - whole
+		frac.Basis = one.Basis * frac.Qty / one.Qty
+		frac.WashAdj = one.WashAdj * frac.Qty / one.Qty
+
+		one.Qty   = whole
+		one.Basis = one.Basis - frac.Basis
+		one.WashAdj = one.WashAdj - frac.WashAdj
+		remaining[i] = one
+
+		lots = append(lots, frac)
+	}
+	if len(lots) == 0 {
+		return cut, queue
+	}
+
+	cut.Closed = this.closures(lots, side, px, 0, 0)
+	for i := range cut.Closed {
+		cut.Closed[i].Exit = exitLieu
+		cut.Qty   += cut.Closed[i].Qty
+		cut.Basis += cut.Closed[i].Basis
+		cut.CF    += cut.Closed[i].Rzd + cut.Closed[i].Basis
+	}
+	cut.Exits = []string{exitLieu}
+	return cut, remaining
+}
+
+// cashOut adds the fractional shares cashed out on the bar to the removals
+// of the side. The cash is added to the net proceeds (see cfRemov).
+func (this *Position) cashOut() {
+	if len(this.Lieu.Closed) == 0 {
+		return
+	}
+	this.Qty.O   -= this.Lieu.Qty
+	this.Basis.O -= this.Lieu.Basis
+
+	// Note: The fractions go first in the ledger, as they are cashed out at 
+	// the start of the bar.
+	n := len(this.Lieu.Closed)
+	this.Closed = append(this.Lieu.Closed[:n:n], this.Closed...)
+}
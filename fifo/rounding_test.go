@@ -0,0 +1,106 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWholeLots(t *testing.T) {
+	tests := []struct {
+		name      string
+		qty, size float64
+		want      float64
+	}{
+		{"whole", 300, 1, 300},
+		{"fraction", 332.5, 1, 332},
+		{"short", -332.5, 1, -332},
+		{"round lot", 332.5, 100, 300},
+		{"rounding error", 299.9999999999, 1, 300},
+		{"less than a lot", 0.75, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := wholeLots(tt.qty, tt.size); got != tt.want {
+			t.Errorf("%s: wholeLots(%v, %v) = %v, want %v", tt.name, tt.qty, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestWholeExits(t *testing.T) {
+	tests := []struct {
+		name     string
+		queue    []Pending
+		n, size  float64
+		exits    []float64
+		rest     []float64
+	}{
+		{
+			name:  "partial exit rounded down",
+			queue: []Pending{{N: 1, Size: 2, Qty: 665, Basis: 66500}},
+			n:     1, size: 1,
+			exits: []float64{332},
+			rest:  []float64{333},
+		},
+		{
+			name:  "whole lot and partial exit",
+			queue: []Pending{{N: 1, Size: 1, Qty: 100, Basis: 10000}, {N: 2, Size: 2, Qty: 201, Basis: 20100}},
+			n:     2, size: 1,
+			exits: []float64{100, 100},
+			rest:  []float64{101},
+		},
+		{
+			name:  "round lots",
+			queue: []Pending{{N: 1, Size: 2, Qty: 500, Basis: 50000}},
+			n:     1, size: 100,
+			exits: []float64{200},
+			rest:  []float64{300},
+		},
+		{
+			name:  "short lot",
+			queue: []Pending{{N: 1, Size: 2, Qty: -665, Basis: -66500}},
+			n:     1, size: 1,
+			exits: []float64{-332},
+			rest:  []float64{-333},
+		},
+		{
+			name:  "nothing left to close",
+			queue: []Pending{{N: 1, Size: 4, Qty: 3, Basis: 300}},
+			n:     1, size: 1,
+			exits: nil,
+			rest:  []float64{3},
+		},
+	}
+	for _, tt := range tests {
+		removed, remaining := split(tt.queue, tt.n)
+		exits, rest := wholeExits(removed, remaining, tt.size)
+
+		if len(exits) != len(tt.exits) || len(rest) != len(tt.rest) {
+			t.Errorf("%s: %d exits and %d lots left, want %d and %d", tt.name, len(exits), len(rest), len(tt.exits), len(tt.rest))
+			continue
+		}
+		var basis float64
+		for i, one := range exits {
+			if math.Abs(one.Qty - tt.exits[i]) > 1e-9 {
+				t.Errorf("%s: exit %d has %v shares, want %v", tt.name, i, one.Qty, tt.exits[i])
+			}
+			basis += one.Basis
+		}
+		for i, one := range rest {
+			if math.Abs(one.Qty - tt.rest[i]) > 1e-9 {
+				t.Errorf("%s: lot %d has %v shares left, want %v", tt.name, i, one.Qty, tt.rest[i])
+			}
+			basis += one.Basis
+		}
+		// The basis is neither lost nor made up
+		var total float64
+		for _, one := range tt.queue {
+			total += one.Basis
+		}
+		if math.Abs(basis - total) > 1e-6 {
+			t.Errorf("%s: basis %v, want %v", tt.name, basis, total)
+		}
+	}
+}
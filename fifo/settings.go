@@ -23,20 +23,127 @@ type Config struct {
 	// Input file names
 	Signals []string `yaml:"signals"`
 
+	// Corporate actions file names (optional), one per input file; an empty 
+	// name stands for no actions
+	Actions []string `yaml:"actions"`
+
 	// A flag showing whether input files contain column titles in the first rows
 	Headers bool     `yaml:"headers"`
 
+	// A flag showing whether input files contain separate short and long 
+	// position columns, so that both books are held at the same time
+	Hedged  bool     `yaml:"hedged"`
+
+	// Column numbers of input files (optional)
+	Columns Layout   `yaml:"columns"`
+
+	// A flag showing that all input files make up a single portfolio trading
+	// against the same cash, with a single output file
+	Portfolio bool   `yaml:"portfolio"`
+
 	// Output file names
 	Results []string `yaml:"results"`
 
+	// Output file names for the ledgers of closed lots (optional)
+	Ledgers []string `yaml:"ledgers"`
+
+	// Output file names for performance summaries (optional)
+	Summaries []string `yaml:"summaries"`
+
+	// Output file names for the tax lots in the format of Form 8949 (optional)
+	TaxLots []string `yaml:"tax_lots"`
+
+	// Output file names for the realized results by years, sides and holding
+	// periods (optional)
+	TaxReports []string `yaml:"tax_reports"`
+
+	// Output file names for the wash-sale adjustments (optional)
+	WashReports []string `yaml:"wash_reports"`
+
+	// The layout of bar IDs as dates in the Go format, e.g. '2006-01-02' 
+	// (optional); needed for the tax reports and the wash-sale mode
+	DateLayout string `yaml:"date_layout"`
+
 	// Starting asset value, cash initially allocated for trading
 	Cash    float64  `yaml:"cash"`
 
 	// Cash limit of exposure per position
 	Lim     float64  `yaml:"limit"`
 
-	// Broker commission
+	// Reinvestment of profits: no (default), nav or equity
+	Reinvest string  `yaml:"reinvest"`
+
+	// Fraction of NAV or of realized equity exposed per position when profits 
+	// are reinvested
+	Fraction float64 `yaml:"fraction"`
+
+	// Position sizing model (optional): notional (default), shares, 
+	// percent_nav or volatility
+	Sizing  Sizing   `yaml:"sizing"`
+
+	// A flag showing that new entries buy (or sell) whole lots of shares only
+	RoundShares bool `yaml:"round_shares"`
+
+	// The number of shares in a whole lot, 1 (default) or e.g. 100
+	LotSize float64  `yaml:"lot_size"`
+
+	// Broker commission per share
 	Fee     float64  `yaml:"commission"`
+
+	// Commission model (optional), in place of the commission per share
+	Commission Commission `yaml:"commission_model"`
+
+	// Slippage model (optional)
+	Slippage Slippage     `yaml:"slippage"`
+
+	// Borrow fees and margin interest (optional)
+	Financing Financing   `yaml:"financing"`
+
+	// Marking of positions to market: close (default), mid or bidask
+	Mark    string   `yaml:"mark"`
+
+	// Policy on entries overdrawing cash: allow (default), reject or scale
+	CashPolicy string `yaml:"cash_policy"`
+
+	// A flag showing that exits realizing a loss are blocked
+	NoLoss  bool     `yaml:"no_loss_exit"`
+
+	// The maximum number of bars in a row an exit may be blocked; 0 (default)
+	// for no limit
+	MaxBlock int     `yaml:"max_block_bars"`
+
+	// The number of bars between a signal and its execution; 0 (default) for
+	// the execution on the bar of the signal
+	Delay   int      `yaml:"execution_delay"`
+
+	// Signals as target positions (default) or as order quantities: target
+	// or delta
+	SignalMode string `yaml:"signal_mode"`
+
+	// A flag showing that losses washed by replacement lots are disallowed
+	WashSale bool    `yaml:"wash_sale"`
+
+	// The number of days either side of a loss exit, 30 (default) or e.g. 60
+	WashWindow int   `yaml:"wash_window"`
+
+	// Protective exits (optional): stop-loss, take-profit and trailing stop
+	Stops   Stops    `yaml:"stops"`
+
+	// The order of the high and the low within a bar: worst (default), 
+	// high_first, low_first or nearest
+	Intrabar string  `yaml:"intrabar"`
+
+	// Lot-relief policy: fifo (default), lifo, hifo, lofo or average
+	Relief  string   `yaml:"lot_relief"`
+
+	// Input validation mode: strict (default) or lenient
+	Validation string `yaml:"validation"`
+
+	// The number of bars a year to annualize statistics, 252 by default
+	BarsPerYear float64 `yaml:"bars_per_year"`
+
+	// Annual risk-free rate for the Sharpe and Sortino ratios
+	RiskFree    float64 `yaml:"risk_free"`
 }
 
 // Params is the object for parameters
@@ -47,11 +154,265 @@ type Params struct {
 	// Cash limit of exposure per position
 	Lim     float64
 
-	// Broker's commission
+	// Reinvestment of profits: no (default), nav or equity
+	Reinvest string
+
+	// Fraction of NAV or of realized equity exposed per position when profits 
+	// are reinvested; the ratio of the limit to the cash by default
+	Fraction float64
+
+	// Position sizing model; the notional exposure per position applies if 
+	// no model is set
+	Sizing  Sizing
+
+	// A flag showing that the quantities of new entries are rounded down to
+	// whole lots of shares, the exposure left unspent being kept as cash, and
+	// fractional shares left by splits are cashed out
+	RoundShares bool
+
+	// The number of shares in a whole lot; 1 share by default
+	LotSize float64
+
+	// Broker's commission per share
 	Fee     float64
+
+	// Commission model; the commission per share applies if no model is set
+	Commission Commission
+
+	// Slippage model; no slippage if no model is set
+	Slippage Slippage
+
+	// Borrow fees of short positions and interest on negative cash accrued
+	// on every bar; none if no rates are set
+	Financing Financing
+
+	// Marking of positions to market: close (default), mid or bidask
+	Mark    string
+
+	// Policy on entries overdrawing cash: allow (default), reject or scale
+	CashPolicy string
+
+	// A flag showing that exits realizing a loss on the lots being relieved 
+	// are blocked until the exit is at breakeven or better
+	NoLoss  bool
+
+	// The maximum number of bars in a row an exit may be blocked; 0 for no
+	// limit
+	MaxBlock int
+
+	// The number of bars between a signal and its execution: the signal of 
+	// bar t is filled on bar t+N at the open if known, otherwise at the trade
+	// price; 0 for the execution on the bar of the signal
+	Delay   int
+
+	// Signals as 'target' positions (default) or as order quantities, 'delta',
+	// applied to the positions held
+	SignalMode string
+
+	// A flag showing that the loss on a lot is disallowed and added to the 
+	// tax basis of replacement lots opened on the same side within the 
+	// wash-sale window
+	WashSale bool
+
+	// The number of days either side of a loss exit within which replacement
+	// lots wash the loss; 30 days by default
+	WashWindow int
+
+	// Protective exits overriding the signals; none if no levels are set
+	Stops   Stops
+
+	// The order of the high and the low within a bar: worst (default), 
+	// high_first, low_first or nearest
+	Intrabar string
 	
 	// A flag showing whether input files contain column titles in the first row
 	Headers bool
+
+	// A flag showing whether the short and the long books are held at the 
+	// same time (hedged mode)
+	Hedged  bool
+
+	// Column numbers of input files
+	Columns Layout
+
+	// Lot-relief policy: fifo (default), lifo, hifo, lofo or average
+	Relief  string
+
+	// Input validation mode: strict (default) aborts the calculation on bad 
+	// rows, lenient skips them
+	Validation string
+
+	// The layout of bar IDs as dates in the Go format, e.g. '2006-01-02'; 
+	// bar IDs are free-form if no layout is set
+	DateLayout string
+
+	// The number of bars a year to annualize statistics
+	BarsPerYear float64
+
+	// Annual risk-free rate
+	RiskFree    float64
+}
+
+// Files holds full names of the input and output files of a single run. 
+// Optional outputs are not written if their names are empty.
+type Files struct {
+	// Input file with prices and signals
+	Signals string
+
+	// Input file with corporate actions (optional)
+	Actions string
+
+	// Output file for the results
+	Results string
+
+	// Output file for the ledger of closed lots (optional)
+	Ledger  string
+
+	// Output file for the performance summary (optional)
+	Summary string
+
+	// Output file for the tax lots in the format of Form 8949 (optional)
+	TaxLots string
+
+	// Output file for the realized results by years, sides and holding 
+	// periods (optional)
+	TaxReport string
+
+	// Output file for the wash-sale adjustments (optional)
+	Wash    string
+}
+
+// PortfolioFiles holds full names of the input and output files of a 
+// portfolio run. Optional outputs are not written if their names are empty.
+type PortfolioFiles struct {
+	// Input files with prices and signals
+	Signals []string
+
+	// Input files with corporate actions (optional), one per input file; an
+	// empty name stands for no actions
+	Actions []string
+
+	// Output file for the results
+	Results string
+
+	// Output file for the ledger of closed lots (optional)
+	Ledger  string
+
+	// Output file for the performance summary (optional)
+	Summary string
+
+	// Output file for the tax lots in the format of Form 8949 (optional)
+	TaxLots string
+
+	// Output file for the realized results by years, sides and holding 
+	// periods (optional)
+	TaxReport string
+
+	// Output file for the wash-sale adjustments (optional)
+	Wash    string
+}
+
+// Params returns the parameters of calculation set in the config.
+// Note: the same parameters for all inputs.
+func (c Config) Params() Params {
+	return Params{
+		Cash:    c.Cash,
+		Lim:     c.Lim,
+		Fee:     c.Fee,
+
+		Commission: c.Commission,
+		Slippage:   c.Slippage,
+		Financing:  c.Financing,
+		Mark:       c.Mark,
+		CashPolicy: c.CashPolicy,
+		NoLoss:     c.NoLoss,
+		MaxBlock:   c.MaxBlock,
+		Delay:      c.Delay,
+		SignalMode: c.SignalMode,
+		WashSale:   c.WashSale,
+		WashWindow: c.WashWindow,
+		Stops:      c.Stops,
+		Intrabar:   c.Intrabar,
+
+		Reinvest: c.Reinvest,
+		Fraction: c.Fraction,
+		Sizing:   c.Sizing,
+
+		RoundShares: c.RoundShares,
+		LotSize:     c.LotSize,
+
+		Headers: c.Headers,
+		Hedged:  c.Hedged,
+		Columns: c.Columns,
+		Relief:  c.Relief,
+
+		Validation:  c.Validation,
+		DateLayout:  c.DateLayout,
+		BarsPerYear: c.BarsPerYear,
+		RiskFree:    c.RiskFree,
+	}
+}
+
+// Files returns full names of the files of the i-th run.
+func (c Config) Files(i int) Files {
+	files := Files{
+		Signals: c.Home + c.Signals[i],
+		Results: c.Home + c.Results[i],
+	}
+	if i < len(c.Actions) && len(c.Actions[i]) > 0 {
+		files.Actions = c.Home + c.Actions[i]
+	}
+	if i < len(c.Ledgers) {
+		files.Ledger = c.Home + c.Ledgers[i]
+	}
+	if i < len(c.Summaries) {
+		files.Summary = c.Home + c.Summaries[i]
+	}
+	if i < len(c.TaxLots) {
+		files.TaxLots = c.Home + c.TaxLots[i]
+	}
+	if i < len(c.TaxReports) {
+		files.TaxReport = c.Home + c.TaxReports[i]
+	}
+	if i < len(c.WashReports) {
+		files.Wash = c.Home + c.WashReports[i]
+	}
+	return files
+}
+
+// PortfolioFiles returns full names of the files of the portfolio run. The
+// first output files listed are used.
+func (c Config) PortfolioFiles() PortfolioFiles {
+	var files PortfolioFiles
+
+	for _, one := range c.Signals {
+		files.Signals = append(files.Signals, c.Home + one)
+	}
+	for _, one := range c.Actions {
+		if len(one) > 0 {
+			one = c.Home + one
+		}
+		files.Actions = append(files.Actions, one)
+	}
+	if len(c.Results) > 0 {
+		files.Results = c.Home + c.Results[0]
+	}
+	if len(c.Ledgers) > 0 {
+		files.Ledger = c.Home + c.Ledgers[0]
+	}
+	if len(c.Summaries) > 0 {
+		files.Summary = c.Home + c.Summaries[0]
+	}
+	if len(c.TaxLots) > 0 {
+		files.TaxLots = c.Home + c.TaxLots[0]
+	}
+	if len(c.TaxReports) > 0 {
+		files.TaxReport = c.Home + c.TaxReports[0]
+	}
+	if len(c.WashReports) > 0 {
+		files.Wash = c.Home + c.WashReports[0]
+	}
+	return files
 }
 
 // ReadConfig parses a YAML config file .
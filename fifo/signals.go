@@ -8,17 +8,14 @@
 package fifo
 
 import (
-	"fmt"
 	"math"
-	"strconv"
 )
 
-const fairSize int = 9999
-
 // iniSignals puts initial signals into the Asset object.
-// No parsing errors are taken into account.
-func (this *Asset) iniSignals(sigs Trades) {
-	this.Bar = sigs.Dt
+func (this *Asset) iniSignals(bar Bar, hedged bool) {
+	this.Bar       = bar.ID
+	this.SignalBar = bar.SignalID
+	this.Date      = bar.Date
 
 	this.S.Pos.I = 0
 	this.S.Pos.O = 0
@@ -27,15 +24,19 @@ func (this *Asset) iniSignals(sigs Trades) {
 	this.L.Pos.O = 0
 	this.L.Pos.E = 0
 
-	// Note: No parsing errors taken into account
-	position, err := strconv.Atoi(sigs.SL)
-	if err != nil {
-		msg := "WARNING! '" + sigs.SL + "' read as '" + strconv.Itoa(position) + "'"
-		warning(msg, err)
+	if hedged {
+		// Both books are entered independently
+		this.S.Pos.target(roundSize(abs(bar.Short)), 0)
+		this.L.Pos.target(roundSize(bar.Long), 0)
+		this.S.Pos.E = this.S.Pos.I
+		this.L.Pos.E = this.L.Pos.I
+		return
 	}
 
+	position := bar.Position
+
 	// Note: Sign convention. The sizes of all positions are positive.
-	size := int(math.Abs(float64(position)))
+	size := roundSize(math.Abs(position))
 	sign := sign(position)
 	
 	switch {
@@ -53,24 +54,28 @@ func (this *Asset) iniSignals(sigs Trades) {
 }
 
 // signals puts signals into the Asset object.
-// No parsing errors are taken into account.
-func (this *Asset) signals(sigs Trades, prev Asset) {
-	this.Bar = sigs.Dt
+// In the hedged mode, the short and the long books move independently, 
+// otherwise the position is a single signed number.
+func (this *Asset) signals(bar Bar, prev Asset, hedged bool) {
+	this.Bar       = bar.ID
+	this.SignalBar = bar.SignalID
+	this.Date      = bar.Date
 
 	this.S.Pos.I = 0
 	this.S.Pos.O = 0
 	this.L.Pos.I = 0
 	this.L.Pos.O = 0
 
-	// Note: No parsing errors taken into account
-	position, err := strconv.Atoi(sigs.SL)
-	if err != nil {
-		msg := "WARNING! '" + sigs.SL + "' read as '" + strconv.Itoa(position) + "'"
-		warning(msg, err)
+	if hedged {
+		this.S.Pos.target(roundSize(abs(bar.Short)), prev.S.Pos.E)
+		this.L.Pos.target(roundSize(bar.Long), prev.L.Pos.E)
+		return
 	}
 
+	position := bar.Position
+
 	// Note: Sign convention. The sizes of all positions are positive.
-	size := int(math.Abs(float64(position)))
+	size := roundSize(math.Abs(position))
 	sign := sign(position)
 
 	// Flip over
@@ -104,19 +109,19 @@ func (this *Asset) signals(sigs Trades, prev Asset) {
 
 		case shortGrow:
 			// Increase the short
-			this.S.Pos.I = size - prev.S.Pos.E
+			this.S.Pos.I = roundSize(size - prev.S.Pos.E)
 
 		case shortDecl:
 			// Reduce the short
-			this.S.Pos.O = prev.S.Pos.E - size
+			this.S.Pos.O = roundSize(prev.S.Pos.E - size)
 
 		case longGrow:
 			// Increase the long
-			this.L.Pos.I = size - prev.L.Pos.E
+			this.L.Pos.I = roundSize(size - prev.L.Pos.E)
 
 		case longDecl:
 			// Reduce the long
-			this.L.Pos.O = prev.L.Pos.E - size
+			this.L.Pos.O = roundSize(prev.L.Pos.E - size)
 
 		case short2zero:
 			// Exit short
@@ -131,24 +136,52 @@ func (this *Asset) signals(sigs Trades, prev Asset) {
 		}
 }
 
+// target sets the size of a new bet or of a removed position, so that one 
+// side of bet would attain the required size.
+func (this *IOE) target(size, prev float64) {
+	switch {
+	case size > prev:
+		// Increase the position
+		this.I = roundSize(size - prev)
+
+	case size < prev:
+		// Reduce the position
+		this.O = roundSize(prev - size)
+
+	default:
+		// Do nothing
+	}
+}
+
 // posEnd for the ending position size.
 func (this *Asset) posEnd(prev Asset) {
 	// Note: Sign convention. The sizes of all positions are positive.
-	this.S.Pos.E = prev.S.Pos.E + this.S.Pos.I - this.S.Pos.O
-	this.L.Pos.E = prev.L.Pos.E + this.L.Pos.I - this.L.Pos.O
+	this.S.Pos.E = roundSize(prev.S.Pos.E + this.S.Pos.I - this.S.Pos.O)
+	this.L.Pos.E = roundSize(prev.L.Pos.E + this.L.Pos.I - this.L.Pos.O)
+}
 
-	// Unreasonably big position sizes are not expected.
-	if this.S.Pos.E + this.L.Pos.E > fairSize {
-		excl := "How about trying a position size smaller than " + strconv.Itoa(fairSize + 1) + " with a higher limit per position?"
-		fmt.Printf("%s\n", excl)
-	}
+// roundSize rounds a position size to a billionth of a position, so that 
+// fractional sizes do not drift by rounding errors as they add up. Integer 
+// sizes are left intact.
+func roundSize(x float64) float64 {
+	return math.Round(x * 1e9) / 1e9
+}
+
+// abs returns the absolute value of a position size
+func abs(x float64) float64 {
+	return math.Abs(x)
 }
 
-// sign returns a sign of an integer argument
-func sign(x int) int {
-	if x == 0 {
+// sign returns a sign of a position size
+func sign(x float64) int {
+	switch {
+	case x < 0:
+		return -1
+
+	case x > 0:
+		return 1
+
+	default:
 		return 0
-	} else {
-		return x / int(math.Abs(float64(x)))
 	}
 }
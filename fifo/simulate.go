@@ -0,0 +1,213 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Result holds the results of a simulation.
+type Result struct {
+	// Results of simulated trading, one per bar
+	Assets  []Asset
+
+	// The ledger of closed lots in the order of closing
+	Ledger  []Closed
+
+	// Performance statistics
+	Summary Summary
+
+	// Realized results by years, sides and holding periods; empty if no 
+	// date layout is set
+	Tax     []TaxBucket
+
+	// Wash-sale adjustments in the order of the bars; empty if the wash-sale
+	// mode is off
+	Wash    []WashSale
+
+	// The name of the lot-relief policy which produced the results
+	Relief  string
+
+	// Input data problems; the respective rows are skipped in the lenient 
+	// validation mode
+	Problems Problems
+}
+
+// Simulate runs trade result calculations on the bars held in memory.
+// It neither prints anything nor touches the file system.
+func Simulate(ctx context.Context, bars []Bar, par Params) (Result, error) {
+	var res Result
+
+	if len(bars) == 0 {
+		return res, errors.New("no bars to simulate")
+	}
+
+	bars, problems, err := checkBars(bars, par)
+	res.Problems = problems
+	if err != nil {
+		return res, err
+	}
+
+	if n := len(Instruments(bars, "")); n > 1 {
+		return res, fmt.Errorf("%d symbols found; several instruments are simulated in the portfolio mode", n)
+	}
+
+	q, err := par.args()
+	if err != nil {
+		return res, err
+	}
+	q.Bars = delayed(bars, q.Delay)
+
+	values, err := fifo(ctx, q)
+	if err != nil {
+		return res, err
+	}
+
+	closed := ledger(values)
+	adjustments := washes(values)
+	washLedger(closed, adjustments)
+
+	sum := summarize(values, closed, par.BarsPerYear, par.RiskFree)
+	sum.Relief = q.Relief.Name()
+
+	res = Result{
+		Assets:  values,
+		Ledger:  closed,
+		Wash:    adjustments,
+		Summary: sum,
+		Relief:  q.Relief.Name(),
+
+		Problems: problems,
+	}
+	if len(par.DateLayout) > 0 {
+		res.Tax = taxReport(closed)
+	}
+	return res, nil
+}
+
+// args checks the parameters and returns the arguments of calculation, 
+// except for the bars.
+func (par Params) args() (argsFIFO, error) {
+	var q argsFIFO
+
+	policy, err := NewRelief(par.Relief)
+	if err != nil {
+		return q, err
+	}
+
+	reinvest, err := reinvestMode(par.Reinvest)
+	if err != nil {
+		return q, err
+	}
+
+	fees, err := NewCommission(par.Commission, par.Fee)
+	if err != nil {
+		return q, err
+	}
+
+	slip, err := NewSlippage(par.Slippage)
+	if err != nil {
+		return q, err
+	}
+
+	mark, err := markMode(par.Mark)
+	if err != nil {
+		return q, err
+	}
+
+	cash, err := cashPolicy(par.CashPolicy)
+	if err != nil {
+		return q, err
+	}
+
+	block, err := maxBlock(par.MaxBlock)
+	if err != nil {
+		return q, err
+	}
+
+	stops, err := newOverlay(par.Stops)
+	if err != nil {
+		return q, err
+	}
+
+	order, err := intrabarOrder(par.Intrabar)
+	if err != nil {
+		return q, err
+	}
+
+	delay, err := execDelay(par.Delay)
+	if err != nil {
+		return q, err
+	}
+
+	delta, err := deltaMode(par.SignalMode)
+	if err != nil {
+		return q, err
+	}
+
+	sizing, err := NewSizing(par.Sizing, par.BarsPerYear)
+	if err != nil {
+		return q, err
+	}
+
+	round, err := roundLot(par.RoundShares, par.LotSize)
+	if err != nil {
+		return q, err
+	}
+
+	wash, err := washWindow(par.WashSale, par.WashWindow, par.DateLayout)
+	if err != nil {
+		return q, err
+	}
+
+	finance, err := newFinancing(par.Financing, par.Columns.Borrow > 0, par.DateLayout, par.BarsPerYear)
+	if err != nil {
+		return q, err
+	}
+
+	q = argsFIFO{
+		Hedged:   par.Hedged,
+		Cashbase: par.Cash,
+		Lim:      par.Lim,
+		Reinvest: reinvest,
+		Sizing:   sizing,
+		Round:    round,
+		Fraction: par.Fraction,
+		Fees:     fees,
+		Slip:     slip,
+		Mark:     mark,
+		Relief:   policy,
+
+		CashPolicy: cash,
+		NoLoss:     par.NoLoss,
+		MaxBlock:   block,
+		Stops:      stops,
+		Intrabar:   order,
+		Delay:      delay,
+		Delta:      delta,
+		Wash:       wash,
+		Finance:    finance,
+	}
+	return q, nil
+}
+
+// SimulateReader runs trade result calculations on the bars read in the CSV 
+// format, as in input files. The name of the input is used in problem reports.
+func SimulateReader(ctx context.Context, r io.Reader, name string, par Params) (Result, error) {
+	bars, problems, err := ReadBars(r, name, par)
+	if err != nil {
+		return Result{Problems: problems}, err
+	}
+
+	res, err := Simulate(ctx, bars, par)
+	res.Problems = append(problems, res.Problems...)
+	return res, err
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Reinvestment modes
+const (
+	// No reinvestment, the exposure per position is the fixed limit
+	reinvestNo     string = "no"
+	// The exposure per position is a fraction of NAV
+	reinvestNAV    string = "nav"
+	// The exposure per position is a fraction of realized equity, i.e. the
+	// initially allocated cash plus realized returns
+	reinvestEquity string = "equity"
+)
+
+// reinvestMode returns the reinvestment mode by its config name: 'no'
+// (default), 'nav' or 'equity'.
+func reinvestMode(name string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(name)); mode {
+	case "", reinvestNo:
+		return reinvestNo, nil
+
+	case reinvestNAV, reinvestEquity:
+		return mode, nil
+
+	default:
+		return "", fmt.Errorf("unknown reinvestment mode '%s'", name)
+	}
+}
+
+// exposure returns the exposure per position for new entries by the sizing
+// model.
+func (this *Asset) exposure(acct account, q argsFIFO) float64 {
+	return q.Sizing.Exposure(this.notional(acct, q), acct.NAV, this.Pxs)
+}
+
+// notional returns the notional exposure per position for new entries. 
+// Profits are reinvested (compounded) by sizing positions as a fraction of 
+// NAV or of realized equity of the account as at the end of the previous bar.
+// Note: The fraction defaults to the ratio of the limit to the cash base,
+// so that the first entry is the same as with no reinvestment.
+func (this *Asset) notional(acct account, q argsFIFO) float64 {
+	var base float64
+
+	fraction := q.Fraction
+	if fraction <= 0 {
+		fraction = ratio(q.Lim, q.Cashbase)
+	}
+
+	switch {
+	case q.Reinvest == reinvestNo:
+		return q.Lim
+
+	case q.Reinvest == reinvestNAV:
+		base = acct.NAV
+
+	case q.Reinvest == reinvestEquity:
+		base = acct.Equity
+	}
+	// Note: No exposure once the base is lost.
+	return max(0, fraction * base)
+}
+
+// SizingModel sizes new entries by the exposure per position, i.e. the net
+// amount paid for buying (or received from selling) the stock of a position.
+type SizingModel interface {
+	// Exposure returns the exposure per position of new entries on the bar,
+	// given the notional exposure per position (the limit, or a fraction of
+	// NAV or of realized equity if profits are reinvested), NAV as at the end
+	// of the previous bar and the prices of the bar.
+	Exposure(notional, nav float64, pxs Prices) float64
+}
+
+// Sizing holds the settings of a position sizing model.
+type Sizing struct {
+	// Model: notional (default), shares, percent_nav or volatility
+	Model  string  `yaml:"model"`
+
+	// The number of shares per position, the percent of NAV per position or
+	// the target annual volatility in percent, depending on the model
+	Value  float64 `yaml:"value"`
+
+	// The number of bars of the volatility window, 20 by default
+	Window int     `yaml:"window"`
+}
+
+// Default number of bars of the volatility window
+const defaultWindow int = 20
+
+// NewSizing returns the position sizing model set in the config. Volatility
+// is annualized by the number of bars a year, 252 if not set.
+func NewSizing(s Sizing, perYear float64) (SizingModel, error) {
+	if s.Value < 0 {
+		return nil, fmt.Errorf("sizing value %v is negative", s.Value)
+	}
+	if s.Window < 0 || s.Window == 1 {
+		return nil, fmt.Errorf("volatility window %d is less than 2 bars", s.Window)
+	}
+
+	model := strings.ToLower(strings.TrimSpace(s.Model))
+	unset := fmt.Errorf("sizing value must be set for the '%s' model", model)
+
+	switch model {
+	case "", "notional":
+		return notionalSize{}, nil
+
+	case "shares":
+		if s.Value == 0 {
+			return nil, unset
+		}
+		return fixedShares{Shares: s.Value}, nil
+
+	case "percent_nav":
+		if s.Value == 0 {
+			return nil, unset
+		}
+		return percentNAV{Rate: s.Value / 100}, nil
+
+	case "volatility":
+		if s.Value == 0 {
+			return nil, unset
+		}
+		window := s.Window
+		if window == 0 {
+			window = defaultWindow
+		}
+		if perYear <= 0 {
+			perYear = barsPerYear
+		}
+		return volTarget{Target: s.Value / 100, Window: window, PerYear: perYear}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown sizing model '%s'", s.Model)
+	}
+}
+
+// notionalSize takes the notional exposure per position as is.
+type notionalSize struct{}
+
+func (notionalSize) Exposure(notional, nav float64, pxs Prices) float64 { return notional }
+
+// fixedShares enters a fixed number of shares per position. The exposure is
+// the value of the shares at the quote of a buy; the quantity is set by the
+// number of shares rather than by the exposure (see qtyNew).
+type fixedShares struct {
+	Shares float64
+}
+
+func (this fixedShares) Exposure(notional, nav float64, pxs Prices) float64 {
+	return this.Shares * pxs.quote(false)
+}
+
+// percentNAV sizes positions as a fraction of NAV, whatever the reinvestment
+// mode.
+// Note: No exposure once NAV is lost.
+type percentNAV struct {
+	Rate float64
+}
+
+func (this percentNAV) Exposure(notional, nav float64, pxs Prices) float64 {
+	return max(0, this.Rate * nav)
+}
+
+// volTarget scales the notional exposure by the ratio of the target annual
+// volatility to the volatility of close-to-close returns over the window.
+// Note: The notional exposure applies as is while the volatility is unknown,
+// i.e. before two returns are known, or zero.
+type volTarget struct {
+	Target  float64
+	Window  int
+	PerYear float64
+}
+
+func (this volTarget) Exposure(notional, nav float64, pxs Prices) float64 {
+	if pxs.Sigma <= 0 {
+		return notional
+	}
+	return notional * this.Target / (pxs.Sigma * math.Sqrt(this.PerYear))
+}
+
+// volatility for the standard deviation of close-to-close returns over the
+// window of bars before the bar, and the window moved on by the return of
+// the bar. Nothing is tracked unless positions are sized by volatility.
+func (this *Asset) volatility(prev Asset, model SizingModel) {
+	this.Pxs.Sigma = 0
+	this.Returns   = nil
+
+	vol, ok := model.(volTarget)
+	if !ok {
+		return
+	}
+	this.Pxs.Sigma = stdev(prev.Returns)
+
+	// Note: The previous close is adjusted for splits, if any.
+	last := prev.Returns
+	if len(last) >= vol.Window {
+		last = last[len(last) - vol.Window + 1:]
+	}
+	this.Returns = make([]float64, len(last), len(last) + 1)
+	copy(this.Returns, last)
+	this.Returns = append(this.Returns, ratio(this.Pxs.Cl, prev.Pxs.Cl) - 1)
+}
+
+// stdev returns the sample standard deviation of the values; 0 if fewer than
+// two values are given.
+func stdev(values []float64) float64 {
+	var mean, sq float64
+
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+	for _, one := range values {
+		mean += one
+	}
+	mean /= n
+	for _, one := range values {
+		sq += (one - mean) * (one - mean)
+	}
+	return math.Sqrt(sq / (n - 1))
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewSizing(t *testing.T) {
+	tests := []struct {
+		name   string
+		sizing Sizing
+		ok     bool
+	}{
+		{"default", Sizing{}, true},
+		{"notional", Sizing{Model: "notional"}, true},
+		{"shares", Sizing{Model: "shares", Value: 100}, true},
+		{"percent of NAV", Sizing{Model: " Percent_NAV ", Value: 10}, true},
+		{"volatility", Sizing{Model: "volatility", Value: 15, Window: 2}, true},
+		{"shares not set", Sizing{Model: "shares"}, false},
+		{"percent not set", Sizing{Model: "percent_nav"}, false},
+		{"target not set", Sizing{Model: "volatility"}, false},
+		{"negative value", Sizing{Model: "shares", Value: -100}, false},
+		{"window of one bar", Sizing{Model: "volatility", Value: 15, Window: 1}, false},
+		{"negative window", Sizing{Model: "volatility", Value: 15, Window: -20}, false},
+		{"unknown model", Sizing{Model: "kelly", Value: 50}, false},
+	}
+	for _, tt := range tests {
+		_, err := NewSizing(tt.sizing, 0)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestExposure(t *testing.T) {
+	pxs    := Prices{Tx: 50, Cl: 50}
+	quoted := Prices{Tx: 50, Cl: 50, Bid: 49.9, Ask: 50.1}
+	volat  := Prices{Tx: 50, Cl: 50, Sigma: 0.02}
+
+	tests := []struct {
+		name     string
+		sizing   Sizing
+		notional float64
+		nav      float64
+		pxs      Prices
+		want     float64
+	}{
+		{"notional", Sizing{}, 100000, 2e6, pxs, 100000},
+		{"shares at the trade price", Sizing{Model: "shares", Value: 300}, 100000, 2e6, pxs, 15000},
+		{"shares at the ask", Sizing{Model: "shares", Value: 300}, 100000, 2e6, quoted, 300 * 50.1},
+		{"percent of NAV", Sizing{Model: "percent_nav", Value: 5}, 100000, 2e6, pxs, 100000},
+		{"percent of NAV lost", Sizing{Model: "percent_nav", Value: 5}, 100000, -2e6, pxs, 0},
+		{"volatility", Sizing{Model: "volatility", Value: 16}, 100000, 2e6, volat, 100000 * 0.16 / (0.02 * math.Sqrt(252))},
+		{"volatility unknown", Sizing{Model: "volatility", Value: 16}, 100000, 2e6, pxs, 100000},
+	}
+	for _, tt := range tests {
+		model, err := NewSizing(tt.sizing, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := model.Exposure(tt.notional, tt.nav, tt.pxs); math.Abs(got - tt.want) > 1e-6 {
+			t.Errorf("%s: Exposure = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStdev(t *testing.T) {
+	tests := []struct {
+		values []float64
+		want   float64
+	}{
+		{nil, 0},
+		{[]float64{0.01}, 0},
+		{[]float64{0.01, 0.01, 0.01}, 0},
+		{[]float64{0.01, -0.01}, math.Sqrt(0.0002)},
+		{[]float64{2, 4, 4, 4, 5, 5, 7, 9}, math.Sqrt(32.0 / 7)},
+	}
+	for _, tt := range tests {
+		if got := stdev(tt.values); math.Abs(got - tt.want) > 1e-12 {
+			t.Errorf("stdev(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	pxs := Prices{Tx: 50, Cl: 50}
+
+	tests := []struct {
+		name     string
+		model    CommissionModel
+		exposure float64
+		sell     bool
+		want     float64 // 0 to check the net amount only
+	}{
+		{"flat per share, buy", perShare{Rate: 0.01}, 10000, false, 10000 / 50.01},
+		{"flat per share, sell", perShare{Rate: 0.01}, 10000, true, 10000 / 49.99},
+		{"per share with a minimum, buy", perShare{Rate: 0.001, Min: 1}, 10000, false, 9999.0 / 50},
+		{"per share with a minimum, sell", perShare{Rate: 0.001, Min: 1}, 10000, true, 10001.0 / 50},
+		{"per share with a maximum, buy", perShare{Rate: 0.005, Max: 0.00001}, 10000, false, 10000 / 50.0005},
+		{"percent, buy", percent{Rate: 0.001}, 10000, false, 10000 / (50 * 1.001)},
+		{"percent, sell", percent{Rate: 0.001}, 10000, true, 10000 / (50 * 0.999)},
+		{"tiered, buy", tiered{Tiers: []Tier{{100, 0.0035}, {0, 0.002}}}, 10000, false, 0},
+		{"tiered, sell", tiered{Tiers: []Tier{{100, 0.0035}, {0, 0.002}}}, 10000, true, 0},
+	}
+	for _, tt := range tests {
+		qty := quantity(tt.model, noSlip{}, tt.exposure, pxs, tt.sell)
+		if tt.want > 0 && math.Abs(qty - tt.want) > 1e-6 {
+			t.Errorf("%s: quantity = %v, want %v", tt.name, qty, tt.want)
+		}
+
+		// A buy does not overspend the exposure, a sale yields it
+		fee := tt.model.Fee(qty, 50, tt.sell)
+		net := qty * (50 + fee)
+		if tt.sell {
+			net = qty * (50 - fee)
+		}
+		if math.Abs(net - tt.exposure) > 1e-6 || (!tt.sell && net > tt.exposure) || (tt.sell && net < tt.exposure) {
+			t.Errorf("%s: net amount %v of %v shares, want %v", tt.name, net, qty, tt.exposure)
+		}
+	}
+
+	// Nothing is bought if the exposure does not cover the minimum commission
+	if qty := quantity(perShare{Rate: 0.005, Min: 1}, noSlip{}, 0.5, pxs, false); qty != 0 {
+		t.Errorf("quantity below the minimum commission = %v, want 0", qty)
+	}
+	if qty := quantity(perShare{Rate: 0.01}, noSlip{}, 0, pxs, false); qty != 0 {
+		t.Errorf("quantity of no exposure = %v, want 0", qty)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SlippageModel computes the move of the fill price against the trader.
+type SlippageModel interface {
+	// Slip returns the move of the fill price per share (not negative) for
+	// an order of qty shares (positive) on the bar with the prices.
+	Slip(qty float64, pxs Prices) float64
+}
+
+// Slippage holds the settings of a slippage model.
+type Slippage struct {
+	// Model: none (default), ticks, bps, range or sqrt
+	Model string  `yaml:"model"`
+
+	// The number of ticks, basis points, the fraction of the bar range or
+	// the impact coefficient, depending on the model
+	Value float64 `yaml:"value"`
+
+	// Tick size for the ticks model, 0.01 by default
+	Tick  float64 `yaml:"tick"`
+}
+
+// Default tick size
+const defaultTick float64 = 0.01
+
+// NewSlippage returns the slippage model set in the config.
+func NewSlippage(s Slippage) (SlippageModel, error) {
+	if s.Value < 0 {
+		return nil, fmt.Errorf("slippage value %v is negative", s.Value)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(s.Model)) {
+	case "", "none":
+		return noSlip{}, nil
+
+	case "ticks":
+		tick := s.Tick
+		if tick <= 0 {
+			tick = defaultTick
+		}
+		return fixedSlip{Amount: s.Value * tick}, nil
+
+	case "bps":
+		return bpsSlip{Rate: s.Value / 10000}, nil
+
+	case "range":
+		return rangeSlip{Fraction: s.Value}, nil
+
+	case "sqrt":
+		return sqrtImpact{Coef: s.Value}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown slippage model '%s'", s.Model)
+	}
+}
+
+// noSlip fills orders exactly at the trade price.
+type noSlip struct{}
+
+func (noSlip) Slip(qty float64, pxs Prices) float64 { return 0 }
+
+// fixedSlip moves the fill price by a fixed amount, e.g. a number of ticks.
+type fixedSlip struct {
+	Amount float64
+}
+
+func (this fixedSlip) Slip(qty float64, pxs Prices) float64 { return this.Amount }
+
+// bpsSlip moves the fill price by a fraction of the trade price.
+type bpsSlip struct {
+	Rate float64
+}
+
+func (this bpsSlip) Slip(qty float64, pxs Prices) float64 { return this.Rate * pxs.Tx }
+
+// rangeSlip moves the fill price by a fraction of the bar range.
+type rangeSlip struct {
+	Fraction float64
+}
+
+func (this rangeSlip) Slip(qty float64, pxs Prices) float64 { return this.Fraction * pxs.Rng }
+
+// sqrtImpact moves the fill price in proportion to the square root of the
+// share of the bar volume taken by the order: coef * price * sqrt(qty / volume).
+// Note: No impact if the volume is unknown.
+type sqrtImpact struct {
+	Coef float64
+}
+
+func (this sqrtImpact) Slip(qty float64, pxs Prices) float64 {
+	if pxs.Vol <= 0 || qty <= 0 {
+		return 0
+	}
+	return this.Coef * pxs.Tx * math.Sqrt(qty / pxs.Vol)
+}
+
+// fill returns the fill price of an order of qty shares: the quote (or the 
+// trade price) moved against the trader, up for buys and down for sells.
+func fill(model SlippageModel, qty float64, pxs Prices, sell bool) float64 {
+	slip  := model.Slip(qty, pxs)
+	price := pxs.quote(sell)
+	if sell {
+		return math.Max(price - slip, 0)
+	}
+	return price + slip
+}
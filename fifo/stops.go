@@ -0,0 +1,440 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Kinds of exits as tagged in the results and in the ledger
+const (
+	exitSignal string = "signal"
+	exitStop   string = "stop"
+	exitTrail  string = "trail"
+	exitTarget string = "target"
+)
+
+// Stops holds the settings of protective exits, i.e. stop-loss, take-profit
+// and trailing-stop levels overriding the signals.
+type Stops struct {
+	// Scope: lot (default), i.e. levels are set for each lot by its entry,
+	// or position, i.e. by the average entry of all lots of a side
+	Scope  string  `yaml:"scope"`
+
+	// Unit of the distances: percent (default) of the price, or atr, i.e.
+	// multiples of the average true range
+	Unit   string  `yaml:"unit"`
+
+	// Distances of the stop-loss and the take-profit levels from the entry
+	// price; 0 for none
+	Loss   float64 `yaml:"stop_loss"`
+	Profit float64 `yaml:"take_profit"`
+
+	// Distance of the trailing stop from the best price since the entry;
+	// 0 for none
+	Trail  float64 `yaml:"trailing"`
+
+	// The number of bars of the average true range, 14 by default
+	Bars   int     `yaml:"atr_bars"`
+}
+
+// Default number of bars of the average true range
+const defaultATRBars int = 14
+
+// overlay holds the checked settings of protective exits.
+type overlay struct {
+	// Levels are set by the average entry of a side rather than by lots
+	position bool
+
+	// Distances are multiples of the average true range rather than percents
+	atr      bool
+
+	loss     float64
+	profit   float64
+	trail    float64
+
+	// The number of bars of the average true range
+	bars     int
+}
+
+// newOverlay checks the settings of protective exits.
+func newOverlay(s Stops) (overlay, error) {
+	var o overlay
+
+	switch scope := strings.ToLower(strings.TrimSpace(s.Scope)); scope {
+	case "", "lot":
+		o.position = false
+
+	case "position":
+		o.position = true
+
+	default:
+		return o, fmt.Errorf("unknown scope of stops '%s'", s.Scope)
+	}
+
+	switch unit := strings.ToLower(strings.TrimSpace(s.Unit)); unit {
+	case "", "percent":
+		o.atr = false
+
+	case "atr":
+		o.atr = true
+
+	default:
+		return o, fmt.Errorf("unknown unit of stops '%s'", s.Unit)
+	}
+
+	if s.Loss < 0 || s.Profit < 0 || s.Trail < 0 {
+		return o, fmt.Errorf("stop distances (%v, %v, %v) must not be negative", s.Loss, s.Profit, s.Trail)
+	}
+	if s.Bars < 0 {
+		return o, fmt.Errorf("number of ATR bars %d is negative", s.Bars)
+	}
+
+	o.loss, o.profit, o.trail = s.Loss, s.Profit, s.Trail
+	o.bars = s.Bars
+	if o.bars == 0 {
+		o.bars = defaultATRBars
+	}
+	return o, nil
+}
+
+// on tells whether any protective exit is set.
+func (o overlay) on() bool {
+	return o.loss > 0 || o.profit > 0 || o.trail > 0
+}
+
+// Triggered holds the protective exits of a side on the bar. The lots are
+// closed ahead of the signal exit, in separate orders by trigger levels.
+type Triggered struct {
+	// Kinds of exits: stop, trail or target, in the order of triggering
+	Exits  []string
+
+	// The number of positions closed
+	Size   float64
+
+	// The quantity (signed as the lots) and the basis of the lots closed
+	Qty    float64
+	Basis  float64
+
+	// Net proceeds, commissions and other fees, slippage cost
+	CF     float64
+	Fees   float64
+	Slip   float64
+
+	// Ledger entries of the lots closed
+	Closed []Closed
+}
+
+// avgRange for the average true range as at the end of the bar: the simple
+// average of the bar ranges until there are enough bars, smoothed by the
+// Wilder's method afterwards.
+func (this *Asset) avgRange(prev Asset, bars int) {
+	n := this.N
+	if n > bars {
+		n = bars
+	}
+	this.Pxs.ATR = prev.Pxs.ATR + (this.Pxs.Rng - prev.Pxs.ATR) / float64(n)
+}
+
+// sides returns the sizes of the short and the long positions signalled on
+// the bar.
+func sides(bar Bar, hedged bool) (float64, float64) {
+	if hedged {
+		return roundSize(abs(bar.Short)), roundSize(bar.Long)
+	}
+	if bar.Position < 0 {
+		return roundSize(-bar.Position), 0
+	}
+	return 0, roundSize(bar.Position)
+}
+
+// suspend carries re-entries suspended after protective exits over to the bar
+// and returns the signals to be followed: the positions held stand for the
+// signals of a side suspended, until its signal changes.
+func (this *Asset) suspend(bar Bar, prev Asset, hedged bool) Bar {
+	sh, ln := sides(bar, hedged)
+
+	this.S.Halt, this.S.Signal = prev.S.Halt && sh == prev.S.Signal, prev.S.Signal
+	this.L.Halt, this.L.Signal = prev.L.Halt && ln == prev.L.Signal, prev.L.Signal
+
+	switch {
+	case hedged:
+		if this.S.Halt {
+			bar.Short = prev.S.Pos.E
+		}
+		if this.L.Halt {
+			bar.Long = prev.L.Pos.E
+		}
+
+	case this.S.Halt || this.L.Halt:
+		bar.Position = prev.L.Pos.E - prev.S.Pos.E
+	}
+	return bar
+}
+
+// protect closes the lots which hit their protective levels on the bar and
+// overrides the signals: the positions closed count towards the exit, and no
+// new positions are entered on the side. It returns a copy of the previous
+// bar with the lots closed removed from the queues.
+func (this *Asset) protect(bar Bar, prev Asset, q argsFIFO) Asset {
+	this.S.Hit = Triggered{}
+	this.L.Hit = Triggered{}
+
+	if !q.Stops.on() {
+		return prev
+	}
+	sh, ln := sides(bar, q.Hedged)
+
+	this.S.Hit, prev.S.Queue = this.triggers(prev.S.Queue, short, prev.Pxs.ATR, q)
+	this.L.Hit, prev.L.Queue = this.triggers(prev.L.Queue, long, prev.Pxs.ATR, q)
+
+	this.S.override(prev.S, sh)
+	this.L.override(prev.L, ln)
+	return prev
+}
+
+// override makes the positions closed by protective exits count towards the
+// exit signalled, if any, and suspends re-entries on the side.
+func (this *Position) override(prev Position, signal float64) {
+	if this.Hit.Size == 0 {
+		return
+	}
+
+	this.Pos.I = 0
+	if this.Pos.O < this.Hit.Size {
+		// The signal exit does not go beyond the protective exits
+		this.Pos.O = this.Hit.Size
+	}
+	this.Pos.E = roundSize(prev.Pos.E - this.Pos.O)
+
+	// Note: Nothing to suspend if the side is signalled out, e.g. on a
+	// flip-over.
+	this.Halt   = signal > 0
+	this.Signal = signal
+}
+
+// triggers finds the lots hitting their protective levels on the bar and
+// closes them. The lots hitting the same level make up a single order. It
+// returns the protective exits and the lots remaining in the queue.
+// Note: In the position scope, all lots of the side are closed together.
+func (this *Asset) triggers(queue []Pending, side string, atr float64, q argsFIFO) (Triggered, []Pending) {
+	var (
+		hit     Triggered
+		levels  []float64
+		kinds   []string
+		orders  [][]Pending
+		remains []Pending
+	)
+	if len(queue) == 0 {
+		return hit, queue
+	}
+
+	add := func(kind string, level float64, lots ...Pending) {
+		for i := range levels {
+			if levels[i] == level && kinds[i] == kind {
+				orders[i] = append(orders[i], lots...)
+				return
+			}
+		}
+		levels = append(levels, level)
+		kinds  = append(kinds, kind)
+		orders = append(orders, lots)
+	}
+
+	switch {
+	case q.Stops.position:
+		if kind, level := q.Stops.trigger(pool(queue), this.Pxs, atr, q.Intrabar); len(kind) > 0 {
+			add(kind, level, queue...)
+		} else {
+			remains = queue
+		}
+
+	default:
+		for _, one := range queue {
+			if kind, level := q.Stops.trigger(one, this.Pxs, atr, q.Intrabar); len(kind) > 0 {
+				add(kind, level, one)
+			} else {
+				remains = append(remains, one)
+			}
+		}
+	}
+
+	if len(orders) == 0 {
+		return hit, queue
+	}
+
+	// Note: Buying to close short position, selling to close long position.
+	sell := side == long
+	for i, lots := range orders {
+		var qty, bas float64
+		for _, one := range lots {
+			qty += one.Qty
+			bas += one.Basis
+			hit.Size = roundSize(hit.Size + one.Size)
+		}
+
+		// Note: The order is filled at the level, slippage accounted.
+		at := this.Pxs
+		at.Tx, at.Bid, at.Ask = levels[i], 0, 0
+		px   := fill(q.Slip, math.Abs(qty), at, sell)
+		fee  := q.Fees.Fee(math.Abs(qty), px, sell)
+		slip := math.Abs(px - levels[i])
+
+		closed := this.closures(lots, side, px, fee, slip)
+		for j := range closed {
+			closed[j].Exit = kinds[i]
+		}
+
+		hit.Qty    += qty
+		hit.Basis  += bas
+		hit.Fees   += math.Abs(qty) * fee
+		hit.Slip   += math.Abs(qty) * slip
+		hit.Closed  = append(hit.Closed, closed...)
+		if sell {
+			hit.CF += +math.Abs(qty) * (px - fee)
+		} else {
+			hit.CF += -math.Abs(qty) * (px + fee)
+		}
+		if !contains(hit.Exits, kinds[i]) {
+			hit.Exits = append(hit.Exits, kinds[i])
+		}
+	}
+	return hit, remains
+}
+
+// trigger returns the kind of the protective exit a lot (or a pool of lots)
+// hits on the bar and the fill level: the bar open on a gap beyond the level,
+// or the level itself; an empty kind if no level is hit. If both a stop and 
+// the target are within the bar, the one reached first by the intrabar order
+// goes. The average true range as at the end of the previous bar is used, 
+// unless it has been recorded at the entry.
+func (o overlay) trigger(lot Pending, pxs Prices, atr float64, order string) (string, float64) {
+	var (
+		stop, target float64
+		kind         string
+	)
+
+	// Note: Sign convention. The short stock has a negative quantity; the
+	// direction of a favourable move of the price.
+	dir := 1.0
+	if lot.Qty < 0 {
+		dir = -1.0
+	}
+	entry := lot.entry()
+
+	unit, trail := entry / 100, lot.Peak / 100
+	if o.atr {
+		unit, trail = lot.ATR, atr
+		if unit == 0 {
+			unit = atr
+		}
+	}
+
+	if o.loss > 0 && unit > 0 {
+		stop, kind = entry - dir * o.loss * unit, exitStop
+	}
+	aim := o.profit > 0 && unit > 0
+	if o.trail > 0 && trail > 0 {
+		level := lot.Peak - dir * o.trail * trail
+		if len(kind) == 0 || dir * (level - stop) > 0 {
+			stop, kind = level, exitTrail
+		}
+	}
+	if aim {
+		target = entry + dir * o.profit * unit
+	}
+
+	// Adverse and favourable extremes of the bar
+	worst, best := pxs.low(), pxs.high()
+	if dir < 0 {
+		worst, best = best, worst
+	}
+	open := pxs.open()
+
+	stopped := len(kind) > 0 && dir * (worst - stop) <= 0
+	reached := aim && dir * (best - target) >= 0
+
+	switch {
+	case len(kind) > 0 && dir * (open - stop) <= 0:
+		return kind, open
+
+	case aim && dir * (open - target) >= 0:
+		return exitTarget, open
+
+	case stopped && (!reached || adverseFirst(order, dir, pxs)):
+		return kind, stop
+
+	case reached:
+		return exitTarget, target
+
+	default:
+		return "", 0
+	}
+}
+
+// pool returns all lots of the queue pooled into a single lot at the average
+// entry price. The best and the worst prices are the ones of all lots.
+func pool(queue []Pending) Pending {
+	var (
+		all    Pending
+		weight float64
+		entry  float64
+		atr    float64
+	)
+	all.Peak  = queue[0].Peak
+	all.Worst = queue[0].Worst
+	for _, one := range queue {
+		all.Size  = roundSize(all.Size + one.Size)
+		all.Qty   += one.Qty
+		all.Basis += one.Basis
+		weight    += math.Abs(one.Qty)
+		entry     += math.Abs(one.Qty) * one.entry()
+		atr       += math.Abs(one.Qty) * one.ATR
+		all.Peak   = better(one.Qty, all.Peak, one.Peak)
+		all.Worst  = better(-one.Qty, all.Worst, one.Worst)
+	}
+	if weight == 0 {
+		return all
+	}
+	// Note: The pool is priced at the average entry with no fees, so that its
+	// entry price is the average one.
+	all.Cost = entry / weight
+	all.ATR  = atr / weight
+	return all
+}
+
+// entry returns the fill price of a lot at the entry, i.e. the net cost price
+// less the fees paid (for long lots) or plus the fees paid (for short lots).
+func (lot Pending) entry() float64 {
+	if lot.Qty < 0 {
+		return lot.Cost + lot.Fee
+	}
+	return lot.Cost - lot.Fee
+}
+
+// exitBy returns the kinds of exits on the bar: protective exits followed by
+// the signal exit, if any; an empty string if there are no exits.
+func (this Position) exitBy() string {
+	kinds := this.Hit.Exits
+	if this.Pos.O > this.Hit.Size {
+		kinds = append(kinds[:len(kinds):len(kinds)], exitSignal)
+	}
+	return strings.Join(kinds, "+")
+}
+
+// contains tells whether the list contains the string.
+func contains(list []string, s string) bool {
+	for _, one := range list {
+		if one == s {
+			return true
+		}
+	}
+	return false
+}
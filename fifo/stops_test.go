@@ -0,0 +1,135 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTrigger(t *testing.T) {
+	// A long lot entered at 100 with the best price of 110 since, and a short
+	// lot entered at 100 with the best price of 90 since
+	long  := Pending{Qty: 100, Cost: 100, Peak: 110}
+	short := Pending{Qty: -100, Cost: 100, Peak: 90}
+
+	tests := []struct {
+		name  string
+		stops Stops
+		lot   Pending
+		pxs   Prices
+		order string
+		kind  string
+		fill  float64
+	}{
+		{
+			name:  "no level hit",
+			stops: Stops{Loss: 5, Profit: 10},
+			lot:   long,
+			pxs:   Prices{Op: 101, Hi: 104, Lo: 97, Cl: 102},
+			kind:  "", fill: 0,
+		},
+		{
+			name:  "stop-loss within the bar",
+			stops: Stops{Loss: 5},
+			lot:   long,
+			pxs:   Prices{Op: 99, Hi: 100, Lo: 94, Cl: 96},
+			kind:  exitStop, fill: 95,
+		},
+		{
+			name:  "stop-loss on a gap fills at the open",
+			stops: Stops{Loss: 5},
+			lot:   long,
+			pxs:   Prices{Op: 90, Hi: 92, Lo: 88, Cl: 91},
+			kind:  exitStop, fill: 90,
+		},
+		{
+			name:  "take-profit within the bar",
+			stops: Stops{Profit: 10},
+			lot:   long,
+			pxs:   Prices{Op: 105, Hi: 112, Lo: 104, Cl: 108},
+			kind:  exitTarget, fill: 110,
+		},
+		{
+			name:  "take-profit on a gap fills at the open",
+			stops: Stops{Profit: 10},
+			lot:   long,
+			pxs:   Prices{Op: 115, Hi: 118, Lo: 113, Cl: 116},
+			kind:  exitTarget, fill: 115,
+		},
+		{
+			name:  "trailing stop from the best price",
+			stops: Stops{Trail: 5},
+			lot:   long,
+			pxs:   Prices{Op: 108, Hi: 109, Lo: 104, Cl: 105},
+			kind:  exitTrail, fill: 104.5,
+		},
+		{
+			name:  "trailing stop above the stop-loss goes",
+			stops: Stops{Loss: 5, Trail: 5},
+			lot:   long,
+			pxs:   Prices{Op: 108, Hi: 109, Lo: 94, Cl: 96},
+			kind:  exitTrail, fill: 104.5,
+		},
+		{
+			name:  "both within the bar, worst order",
+			stops: Stops{Loss: 5, Profit: 10},
+			lot:   long,
+			pxs:   Prices{Op: 100, Hi: 111, Lo: 94, Cl: 100},
+			order: orderWorst,
+			kind:  exitStop, fill: 95,
+		},
+		{
+			name:  "both within the bar, high first",
+			stops: Stops{Loss: 5, Profit: 10},
+			lot:   long,
+			pxs:   Prices{Op: 100, Hi: 111, Lo: 94, Cl: 100},
+			order: orderHighFirst,
+			kind:  exitTarget, fill: 110,
+		},
+		{
+			name:  "short stop-loss within the bar",
+			stops: Stops{Loss: 5},
+			lot:   short,
+			pxs:   Prices{Op: 101, Hi: 106, Lo: 100, Cl: 104},
+			kind:  exitStop, fill: 105,
+		},
+		{
+			name:  "short stop-loss on a gap fills at the open",
+			stops: Stops{Loss: 5},
+			lot:   short,
+			pxs:   Prices{Op: 110, Hi: 112, Lo: 108, Cl: 109},
+			kind:  exitStop, fill: 110,
+		},
+		{
+			name:  "short trailing stop from the best price",
+			stops: Stops{Trail: 5},
+			lot:   short,
+			pxs:   Prices{Op: 92, Hi: 95, Lo: 91, Cl: 93},
+			kind:  exitTrail, fill: 94.5,
+		},
+		{
+			name:  "no intrabar prices: trade and close prices",
+			stops: Stops{Loss: 5},
+			lot:   long,
+			pxs:   Prices{Tx: 97, Cl: 94},
+			kind:  exitStop, fill: 95,
+		},
+	}
+	for _, tt := range tests {
+		o, err := newOverlay(tt.stops)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		order := tt.order
+		if len(order) == 0 {
+			order = orderWorst
+		}
+		kind, fill := o.trigger(tt.lot, tt.pxs, 0, order)
+		if kind != tt.kind || math.Abs(fill - tt.fill) > 1e-9 {
+			t.Errorf("%s: trigger = (%q, %v), want (%q, %v)", tt.name, kind, fill, tt.kind, tt.fill)
+		}
+	}
+}
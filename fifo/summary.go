@@ -0,0 +1,254 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+)
+
+// barsPerYear is the default annualization basis, trading days a year
+const barsPerYear float64 = 252
+
+// Summary holds performance statistics of a run.
+// Note: Ratios are set to 0 where they are undefined, e.g. no losing trades.
+type Summary struct {
+	// The number of bars
+	Bars         int
+
+	// Starting and ending Net Asset Values
+	StartNAV     float64
+	EndNAV       float64
+
+	// Total return, the ratio of the ending NAV to the starting NAV less 1
+	TotalReturn  float64
+
+	// Compound annual growth rate
+	CAGR         float64
+
+	// Annualized volatility of returns per bar
+	Volatility   float64
+
+	// Annualized risk-adjusted returns
+	Sharpe       float64
+	Sortino      float64
+
+	// The ratio of CAGR to the worst drawdown
+	Calmar       float64
+
+	// Worst (maximum) drawdown, a ratio to the initially allocated cash
+	MaxDrawdown  float64
+
+	// The number of closed lots, as listed in the ledger, less the fractional
+	// shares cashed out
+	Trades       int
+
+	// The share of closed lots with a positive realized result
+	WinRate      float64
+
+	// The ratio of gross profit to gross loss of closed lots
+	ProfitFactor float64
+
+	// Average realized results of winning and losing lots
+	AvgWin       float64
+	AvgLoss      float64
+
+	// Average realized result per closed lot
+	Expectancy   float64
+
+	// The share of bars with any position open
+	Exposure     float64
+
+	// Total commissions and other fees paid
+	Commissions  float64
+
+	// Total slippage cost
+	Slippage     float64
+
+	// Total spread cost
+	Spread       float64
+
+	// Total exposure left unspent by rounding quantities to whole lots
+	Residual     float64
+
+	// Total borrow fees and margin interest
+	Borrow       float64
+	Interest     float64
+
+	// The name of the lot-relief policy which produced the results
+	Relief       string
+}
+
+// summarize calculates performance statistics of the results and the ledger 
+// of closed lots. Returns per bar are annualized by the number of bars a year. 
+// The risk-free rate is an annual rate.
+func summarize(values []Asset, closed []Closed, perYear, riskFree float64) Summary {
+	var (
+		sum  Summary
+		rets []float64
+	)
+	if len(values) == 0 {
+		return sum
+	}
+	if perYear <= 0 {
+		perYear = barsPerYear
+	}
+
+	first, last := values[0], values[len(values)-1]
+	sum.Bars        = len(values)
+	sum.StartNAV    = first.NAV
+	sum.EndNAV      = last.NAV
+	sum.TotalReturn = ratio(last.NAV, first.NAV) - 1
+	sum.MaxDrawdown = last.WDD
+
+	// Returns per bar
+	rets = make([]float64, 0, len(values))
+	for i := 1; i < len(values); i++ {
+		rets = append(rets, ratio(values[i].NAV, values[i-1].NAV) - 1)
+	}
+
+	years := float64(len(rets)) / perYear
+	if years > 0 && sum.EndNAV > 0 && sum.StartNAV > 0 {
+		sum.CAGR = math.Pow(sum.EndNAV / sum.StartNAV, 1 / years) - 1
+	}
+
+	// Excess returns per bar over the risk-free rate
+	rf := riskFree / perYear
+	mean, sd, down := moments(rets, rf)
+	sum.Volatility = sd * math.Sqrt(perYear)
+	sum.Sharpe     = ratio(mean - rf, sd) * math.Sqrt(perYear)
+	sum.Sortino    = ratio(mean - rf, down) * math.Sqrt(perYear)
+	sum.Calmar     = ratio(sum.CAGR, sum.MaxDrawdown)
+
+	// Closed lots
+	var (
+		wins, losses int
+		won, lost    float64
+	)
+	for _, one := range closed {
+		if one.Exit == exitLieu {
+			// Fractional shares cashed out are not trades
+			continue
+		}
+		switch {
+		case one.Rzd > 0:
+			wins += 1
+			won  += one.Rzd
+		case one.Rzd < 0:
+			losses += 1
+			lost   += one.Rzd
+		}
+		sum.Trades += 1
+	}
+	sum.WinRate      = ratio(float64(wins), float64(sum.Trades))
+	sum.ProfitFactor = ratio(won, -lost)
+	sum.AvgWin       = ratio(won, float64(wins))
+	sum.AvgLoss      = ratio(lost, float64(losses))
+	sum.Expectancy   = ratio(won + lost, float64(sum.Trades))
+
+	// Time in the market and trading costs
+	var open int
+	for _, one := range values {
+		if one.S.Pos.E != 0 || one.L.Pos.E != 0 {
+			open += 1
+		}
+		sum.Commissions += one.S.Fees + one.L.Fees
+		sum.Slippage    += one.S.Slip + one.L.Slip
+		sum.Spread      += one.S.Spread + one.L.Spread
+		sum.Residual    += one.Residual
+		sum.Borrow      += one.Borrow
+		sum.Interest    += one.Interest
+	}
+	sum.Exposure = ratio(float64(open), float64(len(values)))
+
+	return sum
+}
+
+// moments returns the mean, the sample standard deviation and the downside
+// deviation (below the target) of the returns.
+func moments(rets []float64, target float64) (mean, sd, down float64) {
+	n := float64(len(rets))
+	if n < 2 {
+		return
+	}
+	for _, r := range rets {
+		mean += r
+	}
+	mean /= n
+
+	for _, r := range rets {
+		sd += (r - mean) * (r - mean)
+		if r < target {
+			down += (r - target) * (r - target)
+		}
+	}
+	sd   = math.Sqrt(sd / (n - 1))
+	down = math.Sqrt(down / n)
+	return
+}
+
+// ratio divides a by b, or returns 0 if b is 0.
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// rows lists the statistics as pairs of names and formatted values.
+func (this Summary) rows() [][]string {
+	return [][]string{
+		{"Bars", fmt.Sprintf("%d", this.Bars)},
+		{"StartNAV", fmt.Sprintf("%f", this.StartNAV)},
+		{"EndNAV", fmt.Sprintf("%f", this.EndNAV)},
+		{"TotalReturn", fmt.Sprintf("%f", this.TotalReturn)},
+		{"CAGR", fmt.Sprintf("%f", this.CAGR)},
+		{"Volatility", fmt.Sprintf("%f", this.Volatility)},
+		{"Sharpe", fmt.Sprintf("%f", this.Sharpe)},
+		{"Sortino", fmt.Sprintf("%f", this.Sortino)},
+		{"Calmar", fmt.Sprintf("%f", this.Calmar)},
+		{"MaxDrawdown", fmt.Sprintf("%f", this.MaxDrawdown)},
+		{"Trades", fmt.Sprintf("%d", this.Trades)},
+		{"WinRate", fmt.Sprintf("%f", this.WinRate)},
+		{"ProfitFactor", fmt.Sprintf("%f", this.ProfitFactor)},
+		{"AvgWin", fmt.Sprintf("%f", this.AvgWin)},
+		{"AvgLoss", fmt.Sprintf("%f", this.AvgLoss)},
+		{"Expectancy", fmt.Sprintf("%f", this.Expectancy)},
+		{"Exposure", fmt.Sprintf("%f", this.Exposure)},
+		{"Commissions", fmt.Sprintf("%f", this.Commissions)},
+		{"Slippage", fmt.Sprintf("%f", this.Slippage)},
+		{"Spread", fmt.Sprintf("%f", this.Spread)},
+		{"Residual", fmt.Sprintf("%f", this.Residual)},
+		{"Borrow", fmt.Sprintf("%f", this.Borrow)},
+		{"Interest", fmt.Sprintf("%f", this.Interest)},
+		{"Relief", this.Relief},
+	}
+}
+
+// printSummary prints performance statistics.
+func printSummary(sum Summary) {
+	fmt.Println("Performance summary:")
+	for _, row := range sum.rows() {
+		fmt.Printf("  %-13s %s\n", row[0], row[1])
+	}
+}
+
+// writeCSVsummary exports performance statistics in the CSV format
+func writeCSVsummary(sum Summary, outFile string) {
+	csvNewFile, err := createCSV(outFile)
+	if err != nil {
+		fmt.Println("Output file creating error:", err)
+		return
+	}
+	defer csvNewFile.Close()
+
+	writer := csv.NewWriter(csvNewFile)
+	writer.Write([]string{"Metric", "Value"})
+	writer.WriteAll(sum.rows())
+}
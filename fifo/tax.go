@@ -0,0 +1,256 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Holding periods of closed lots as named in the tax reports
+const (
+	shortTerm string = "short-term"
+	longTerm  string = "long-term"
+)
+
+var (
+	taxLotAttributes string = `Term
+Description
+DateAcquired
+DateSold
+Proceeds
+CostBasis
+Code
+Adjustment
+GainLoss`
+
+	taxReportAttributes string = `Year
+Side
+Term
+Lots
+Proceeds
+CostBasis
+Adjustment
+GainLoss`
+)
+
+// TaxBucket holds the realized results of the lots closed on a side in a
+// calendar year, by the holding period.
+type TaxBucket struct {
+	// The calendar year of the exits
+	Year     int
+
+	// The side of the lots, 'SHORT' or 'LONG'
+	Side     string
+
+	// The holding period: 'short-term' or 'long-term'
+	Term     string
+
+	// The number of closed lots
+	Lots     int
+
+	// Proceeds, the cost basis, fees and wash-sale adjustments accounted, the
+	// losses disallowed by the wash-sale rule and the gain or loss, i.e. the
+	// realized result adjusted by the wash-sale rule
+	Proceeds float64
+	Cost     float64
+	Wash     float64
+	Gain     float64
+}
+
+// barDate parses the bar ID as a date by the layout, e.g. '2006-01-02'.
+// It returns a zero time if no layout is set.
+func barDate(id, layout string) (time.Time, error) {
+	if len(layout) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(layout, strings.TrimSpace(id))
+}
+
+// Term returns the holding period of the lot: long-term if a long lot was
+// held for more than a year, otherwise short-term. Short lots are always
+// short-term, as the gain or loss on a short sale is short-term regardless of
+// how long it is held open (IRC 1233).
+func (this Closed) Term() string {
+	if this.Qty < 0 {
+		return shortTerm
+	}
+	if this.Sold.After(this.Acquired.AddDate(1, 0, 0)) {
+		return longTerm
+	}
+	return shortTerm
+}
+
+// Proceeds returns the proceeds from the sale of the lot, fees subtracted:
+// the exit of a long lot, the entry of a short lot.
+func (this Closed) Proceeds() float64 {
+	// Note: Sign convention. SHORT ==> negative basis, i.e. positive proceeds
+	// from the short sale.
+	if this.Qty < 0 {
+		return -this.Basis
+	}
+	return this.Rzd + this.Basis
+}
+
+// CostBasis returns the cost of the lot, fees added: the entry of a long lot,
+// the exit (cover) of a short lot. The losses disallowed on other lots by the
+// wash-sale rule are added.
+func (this Closed) CostBasis() float64 {
+	if this.Qty < 0 {
+		return -(this.Rzd + this.Basis) + this.WashAdj
+	}
+	return this.Basis + this.WashAdj
+}
+
+// GainLoss returns the gain or loss on the lot for the tax reports: the 
+// realized result adjusted by the wash-sale rule.
+func (this Closed) GainLoss() float64 {
+	return this.Rzd + this.Wash - this.WashAdj
+}
+
+// taxReport sums up the realized results of the closed lots by years of the
+// exits, sides and holding periods.
+func taxReport(closed []Closed) []TaxBucket {
+	var all []TaxBucket
+
+	index := make(map[string]int)
+	for _, one := range closed {
+		year, term := one.Sold.Year(), one.Term()
+
+		key := fmt.Sprintf("%d|%s|%s", year, one.Side, term)
+		i, ok := index[key]
+		if !ok {
+			i = len(all)
+			index[key] = i
+			all = append(all, TaxBucket{Year: year, Side: one.Side, Term: term})
+		}
+		all[i].Lots     += 1
+		all[i].Proceeds += one.Proceeds()
+		all[i].Cost     += one.CostBasis()
+		all[i].Wash     += one.Wash
+		all[i].Gain     += one.GainLoss()
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		switch {
+		case all[i].Year != all[j].Year:
+			return all[i].Year < all[j].Year
+
+		case all[i].Side != all[j].Side:
+			return all[i].Side < all[j].Side
+
+		default:
+			// Note: Short-term goes first, as in Part I of Form 8949.
+			return all[i].Term > all[j].Term
+		}
+	})
+	return all
+}
+
+// description describes the lot as on Form 8949, e.g. '100 sh AAA'. 
+// Fractional quantities are rounded to 6 decimals.
+func (this Closed) description(name string) string {
+	symbol := this.Symbol
+	if len(symbol) == 0 {
+		symbol = name
+	}
+	qty  := math.Round(math.Abs(this.Qty) * 1e6) / 1e6
+	text := formatSize(qty) + " sh " + symbol
+	if this.Qty < 0 {
+		text += " (short sale)"
+	}
+	return text
+}
+
+// writeCSVtaxLots exports the closed lots in the CSV format of Form 8949,
+// one row per lot, short-term lots first. The instrument is named by its
+// symbol, or by the name given in the single-instrument mode.
+func writeCSVtaxLots(closed []Closed, name, outFile string) {
+	var (
+		field []string
+	)
+
+	csvNewFile, err := createCSV(outFile)
+	if err != nil {
+		fmt.Println("Output file creating error:", err)
+		return
+	}
+	defer csvNewFile.Close()
+
+	writer := csv.NewWriter(csvNewFile)
+
+	headers := strings.Split(taxLotAttributes, "\n")
+	writer.Write(headers)
+
+	for _, term := range []string{shortTerm, longTerm} {
+		for _, one := range closed {
+			if one.Term() != term {
+				continue
+			}
+			field = make([]string, len(headers))
+
+			field[0] = term
+			field[1] = one.description(name)
+			field[2] = one.Acquired.Format("01/02/2006")
+			field[3] = one.Sold.Format("01/02/2006")
+			field[4] = fmt.Sprintf("%.2f", one.Proceeds())
+			field[5] = fmt.Sprintf("%.2f", one.CostBasis())
+			field[6] = ""
+			field[7] = ""
+			if one.Wash > 0 {
+				field[6] = washCode
+				field[7] = fmt.Sprintf("%.2f", one.Wash)
+			}
+			field[8] = fmt.Sprintf("%.2f", one.GainLoss())
+
+			writer.Write(field)
+		}
+	}
+	writer.Flush()
+}
+
+// writeCSVtaxReport exports the realized results by years, sides and holding
+// periods in the CSV format.
+func writeCSVtaxReport(buckets []TaxBucket, outFile string) {
+	var (
+		field []string
+	)
+
+	csvNewFile, err := createCSV(outFile)
+	if err != nil {
+		fmt.Println("Output file creating error:", err)
+		return
+	}
+	defer csvNewFile.Close()
+
+	writer := csv.NewWriter(csvNewFile)
+
+	headers := strings.Split(taxReportAttributes, "\n")
+	writer.Write(headers)
+
+	for _, one := range buckets {
+		field = make([]string, len(headers))
+
+		field[0] = strconv.Itoa(one.Year)
+		field[1] = one.Side
+		field[2] = one.Term
+		field[3] = strconv.Itoa(one.Lots)
+		field[4] = fmt.Sprintf("%f", one.Proceeds)
+		field[5] = fmt.Sprintf("%f", one.Cost)
+		field[6] = fmt.Sprintf("%f", one.Wash)
+		field[7] = fmt.Sprintf("%f", one.Gain)
+
+		writer.Write(field)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTerm(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		name     string
+		qty      float64
+		acquired string
+		sold     string
+		want     string
+	}{
+		{"long, held less than a year", 100, "2020-01-02", "2020-12-31", shortTerm},
+		{"long, held exactly a year", 100, "2020-01-02", "2021-01-02", shortTerm},
+		{"long, held more than a year", 100, "2020-01-02", "2021-01-03", longTerm},
+		{"short, held less than a year", -100, "2020-01-02", "2020-12-31", shortTerm},
+		{"short, held more than a year", -100, "2020-01-02", "2022-06-30", shortTerm},
+	}
+	for _, tt := range tests {
+		lot := Closed{Qty: tt.qty, Acquired: day(tt.acquired), Sold: day(tt.sold)}
+		if got := lot.Term(); got != tt.want {
+			t.Errorf("%s: Term() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problem describes a problem found in the input data.
+type Problem struct {
+	// The name of the input, e.g. file name; empty for data held in memory
+	File   string
+
+	// Line number (starting from 1); 0 if the problem concerns the whole input
+	Line   int
+
+	// Column number (starting from 1); 0 if the problem concerns the whole row
+	Column int
+
+	// Description of the problem
+	Msg    string
+}
+
+// Error formats the problem as 'file:line:column: description'.
+func (this Problem) Error() string {
+	var pos []string
+	if len(this.File) > 0 {
+		pos = append(pos, this.File)
+	}
+	if this.Line > 0 {
+		pos = append(pos, strconv.Itoa(this.Line))
+	}
+	if this.Column > 0 {
+		pos = append(pos, strconv.Itoa(this.Column))
+	}
+	if len(pos) == 0 {
+		return this.Msg
+	}
+	return strings.Join(pos, ":") + ": " + this.Msg
+}
+
+// Problems is a list of problems found in the input data. It is returned as
+// an error in the strict validation mode.
+type Problems []Problem
+
+// Error lists all problems, one per line.
+func (this Problems) Error() string {
+	msgs := make([]string, len(this))
+	for i, one := range this {
+		msgs[i] = one.Error()
+	}
+	return fmt.Sprintf("%d input data problem(s):\n%s", len(this), strings.Join(msgs, "\n"))
+}
+
+// record is a row of input data with its line number.
+type record struct {
+	Line   int
+	Fields []string
+}
+
+// strictMode tells whether the validation mode, 'strict' (default) or
+// 'lenient', requires aborting the calculation on bad rows.
+func strictMode(name string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "strict":
+		return true, nil
+
+	case "lenient":
+		return false, nil
+
+	default:
+		return true, fmt.Errorf("unknown validation mode '%s'", name)
+	}
+}
+
+// readRecords reads all rows of CSV data along with their line numbers.
+// Malformed rows are reported as problems and left out.
+func readRecords(r io.Reader, name string) ([]record, Problems, error) {
+	var (
+		all      []record
+		problems Problems
+		parseErr *csv.ParseError
+	)
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if errors.As(err, &parseErr) {
+			problems = append(problems, Problem{
+				File:   name,
+				Line:   parseErr.Line,
+				Column: parseErr.Column,
+				Msg:    parseErr.Err.Error(),
+			})
+			continue
+		}
+		if err != nil {
+			return all, problems, err
+		}
+		line, _ := reader.FieldPos(0)
+		all = append(all, record{Line: line, Fields: fields})
+	}
+	return all, problems, nil
+}
+
+// validate converts the records into bars and reports all problems found, 
+// in addition to the problems found earlier.
+func validate(records []record, problems Problems, name string, par Params) ([]Bar, Problems, error) {
+	var (
+		bars     []Bar
+	)
+	strict, err := strictMode(par.Validation)
+	if err != nil {
+		return nil, problems, err
+	}
+	delta, err := deltaMode(par.SignalMode)
+	if err != nil {
+		return nil, problems, err
+	}
+
+	layout := par.Columns.withDefaults(par.Hedged)
+
+	bars = make([]Bar, 0, len(records))
+	for _, rec := range records {
+		one, found := rec.bar(layout, par.Commission.floor(par.Fee), delta, par.DateLayout)
+		for i := range found {
+			found[i].File = name
+		}
+		if len(found) > 0 {
+			problems = append(problems, found...)
+			continue
+		}
+		bars = append(bars, one)
+	}
+
+	return outcome(bars, problems, strict, name)
+}
+
+// bar parses the record into a bar and checks the values.
+func (rec record) bar(layout Layout, fee float64, delta bool, dates string) (Bar, Problems) {
+	var (
+		one      Bar
+		problems Problems
+	)
+	// Columns which failed to parse
+	bad := make(map[int]bool)
+	add := func(col int, format string, args ...interface{}) {
+		bad[col] = true
+		problems = append(problems, Problem{
+			Line:   rec.Line,
+			Column: col,
+			Msg:    fmt.Sprintf(format, args...),
+		})
+	}
+	number := func(col int, name string) float64 {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field(rec.Fields, col)), 64)
+		if err != nil {
+			add(col, "%s '%s' is not a number", name, field(rec.Fields, col))
+		}
+		return value
+	}
+
+	if width := layout.width(); len(rec.Fields) != width {
+		add(0, "%d columns expected, %d found", width, len(rec.Fields))
+		return one, problems
+	}
+	sig := data2trades(rec.Fields, layout)
+	one.ID     = sig.Dt
+	one.Symbol = strings.TrimSpace(sig.Sym)
+
+	one.Close = number(layout.Close, "close price")
+	one.Trade = number(layout.Trade, "trade price")
+
+	switch {
+	case layout.Position > 0:
+		one.Position = number(layout.Position, "position")
+
+	default:
+		one.Short = number(layout.Short, "short position")
+		one.Long  = number(layout.Long, "long position")
+	}
+	if layout.Open > 0 && layout.High > 0 && layout.Low > 0 {
+		one.Open = number(layout.Open, "open price")
+		one.High = number(layout.High, "high price")
+		one.Low  = number(layout.Low, "low price")
+	}
+	if layout.Volume > 0 {
+		one.Volume = number(layout.Volume, "volume")
+	}
+	if layout.Symbol > 0 && len(one.Symbol) == 0 {
+		add(layout.Symbol, "symbol is empty")
+	}
+	if layout.Bid > 0 && layout.Ask > 0 {
+		one.Bid = number(layout.Bid, "bid price")
+		one.Ask = number(layout.Ask, "ask price")
+	}
+	if layout.Borrow > 0 {
+		one.Borrow = number(layout.Borrow, "borrow rate")
+	}
+
+	for _, p := range one.check(layout, fee, delta, dates) {
+		if bad[p.Column] {
+			continue
+		}
+		p.Line = rec.Line
+		problems = append(problems, p)
+	}
+	one.Date, _ = barDate(one.ID, dates)
+	return one, problems
+}
+
+// check checks the values of the bar. Problems are reported in the columns 
+// of the layout. Negative long positions are sells in the delta signal mode.
+// Bar IDs are checked to be dates if the date layout is set.
+func (this Bar) check(layout Layout, fee float64, delta bool, dates string) Problems {
+	var problems Problems
+
+	if _, err := barDate(this.ID, dates); err != nil {
+		problems = append(problems, Problem{Column: layout.Bar,
+			Msg: fmt.Sprintf("bar ID '%s' is not a date of the layout '%s'", this.ID, dates)})
+	}
+	if !(this.Close > 0) {
+		problems = append(problems, Problem{Column: layout.Close,
+			Msg: fmt.Sprintf("close price %v is not positive", this.Close)})
+	}
+	switch {
+	case !(this.Trade > 0):
+		problems = append(problems, Problem{Column: layout.Trade,
+			Msg: fmt.Sprintf("trade price %v is not positive", this.Trade)})
+
+	case !(this.Trade > fee):
+		// Note: The net price received from selling a share must be positive.
+		problems = append(problems, Problem{Column: layout.Trade,
+			Msg: fmt.Sprintf("trade price %v is not above the commission %v", this.Trade, fee)})
+	}
+	for _, size := range []struct {
+		col   int
+		name  string
+		value float64
+	}{
+		{layout.Position, "position", this.Position},
+		{layout.Short, "short position", this.Short},
+		{layout.Long, "long position", this.Long},
+	} {
+		if math.IsNaN(size.value) || math.IsInf(size.value, 0) {
+			problems = append(problems, Problem{Column: size.col,
+				Msg: fmt.Sprintf("%s %v is not a finite number", size.name, size.value)})
+		}
+	}
+	// Note: Sign convention. SHORT ==> negative size.
+	if this.Short > 0 && !delta {
+		problems = append(problems, Problem{Column: layout.Short,
+			Msg: fmt.Sprintf("short position %v is positive", this.Short)})
+	}
+	if this.Long < 0 && !delta {
+		problems = append(problems, Problem{Column: layout.Long,
+			Msg: fmt.Sprintf("long position %v is negative", this.Long)})
+	}
+	// Note: Zero open, high and low prices stand for no intrabar prices on the
+	// bar.
+	switch {
+	case this.Open < 0 || this.High < 0 || this.Low < 0:
+		problems = append(problems, Problem{Column: layout.Open,
+			Msg: fmt.Sprintf("open %v, high %v or low %v is negative", this.Open, this.High, this.Low)})
+
+	case this.Open == 0 && this.High == 0 && this.Low == 0:
+		// No intrabar prices
+
+	case this.Open == 0 || this.High == 0 || this.Low == 0:
+		problems = append(problems, Problem{Column: layout.Open,
+			Msg: fmt.Sprintf("open %v, high %v and low %v must be all set or all zero", this.Open, this.High, this.Low)})
+
+	case this.High < this.Low:
+		problems = append(problems, Problem{Column: layout.High,
+			Msg: fmt.Sprintf("high %v is below low %v", this.High, this.Low)})
+
+	case this.Open > this.High || this.Open < this.Low || this.Close > this.High || this.Close < this.Low:
+		problems = append(problems, Problem{Column: layout.Open,
+			Msg: fmt.Sprintf("open %v or close %v is outside the range from low %v to high %v",
+				this.Open, this.Close, this.Low, this.High)})
+	}
+	if this.Volume < 0 {
+		problems = append(problems, Problem{Column: layout.Volume,
+			Msg: fmt.Sprintf("volume %v is negative", this.Volume)})
+	}
+	// Note: Zero bid and ask prices stand for no quotes on the bar.
+	switch {
+	case this.Bid < 0 || this.Ask < 0:
+		problems = append(problems, Problem{Column: layout.Bid,
+			Msg: fmt.Sprintf("bid %v or ask %v is negative", this.Bid, this.Ask)})
+
+	case (this.Bid > 0) != (this.Ask > 0):
+		problems = append(problems, Problem{Column: layout.Bid,
+			Msg: fmt.Sprintf("bid %v and ask %v must be both set or both zero", this.Bid, this.Ask)})
+
+	case this.Bid > this.Ask:
+		problems = append(problems, Problem{Column: layout.Bid,
+			Msg: fmt.Sprintf("bid %v is above ask %v", this.Bid, this.Ask)})
+	}
+	if this.Borrow < 0 {
+		problems = append(problems, Problem{Column: layout.Borrow,
+			Msg: fmt.Sprintf("borrow rate %v is negative", this.Borrow)})
+	}
+	return problems
+}
+
+// checkBars checks the values of bars held in memory. Bars are numbered
+// from 1 in place of line numbers.
+func checkBars(bars []Bar, par Params) ([]Bar, Problems, error) {
+	var (
+		good     []Bar
+		problems Problems
+	)
+	strict, err := strictMode(par.Validation)
+	if err != nil {
+		return nil, problems, err
+	}
+	delta, err := deltaMode(par.SignalMode)
+	if err != nil {
+		return nil, problems, err
+	}
+	layout := par.Columns.withDefaults(par.Hedged)
+
+	good = make([]Bar, 0, len(bars))
+	for i, one := range bars {
+		found := one.check(layout, par.Commission.floor(par.Fee), delta, par.DateLayout)
+		for j := range found {
+			found[j].Line = i + 1
+		}
+		if len(found) > 0 {
+			problems = append(problems, found...)
+			continue
+		}
+		one.Date, _ = barDate(one.ID, par.DateLayout)
+		good = append(good, one)
+	}
+
+	return outcome(good, problems, strict, "")
+}
+
+// outcome returns the valid bars along with the problems as warnings, or 
+// the problems as an error if the calculation is to be aborted.
+func outcome(bars []Bar, problems Problems, strict bool, name string) ([]Bar, Problems, error) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+
+	switch {
+	case strict && len(problems) > 0:
+		return nil, problems, problems
+
+	case len(bars) == 0:
+		problems = append(problems, Problem{File: name, Msg: "no valid data rows found"})
+		return nil, problems, problems
+
+	default:
+		return bars, problems, nil
+	}
+}
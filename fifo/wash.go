@@ -0,0 +1,295 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+// Package fifo models the First-In-First-Out position management
+// to calculate results of algorithmic trading by trade signals,
+// given that returns are not reinvested and positions are not rebalanced.
+package fifo
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// The code of the wash-sale adjustment on Form 8949
+const washCode string = "W"
+
+var (
+	washAttributes string = `Side
+EntryBar
+ExitBar
+ReplacementBar
+Bar
+Quantity
+Disallowed`
+)
+
+// WashSale is an adjustment by the wash-sale rule: the loss on (a part of) a
+// closed lot is disallowed and added to the tax basis of a replacement lot 
+// opened on the same side within the window around the exit.
+type WashSale struct {
+	// Symbol of the instrument in the portfolio mode; empty otherwise
+	Symbol      string
+
+	// The side of the lots, 'SHORT' or 'LONG'
+	Side        string
+
+	// The number of the closed lot among the lots closed on the side (see
+	// Closed.Seq), and the bar IDs of its entry and its exit
+	Seq         int
+	EntryBar    string
+	ExitBar     string
+
+	// Bar ID of the entry of the replacement lot
+	Replacement string
+
+	// Bar ID of the bar the adjustment is made on, i.e. the later one of the
+	// exit and the replacement
+	Bar         string
+
+	// The quantity of shares matched
+	Qty         float64
+
+	// The loss disallowed, a positive amount
+	Disallowed  float64
+}
+
+// washLoss is (the part of) a loss on a closed lot not matched with
+// replacement shares yet.
+type washLoss struct {
+	// The closed lot, see WashSale
+	Seq      int
+	EntryN   int
+	EntryBar string
+	ExitBar  string
+
+	// The date of the exit
+	Sold     time.Time
+
+	// The quantity of shares not matched yet, a positive amount
+	Qty      float64
+
+	// The loss per share, a positive amount
+	Loss     float64
+}
+
+// washWindow returns the number of days either side of a loss exit within
+// which replacement lots wash the loss: 30 days by default; 0 if the
+// wash-sale mode is off. The mode needs the date layout.
+func washWindow(on bool, days int, dates string) (int, error) {
+	switch {
+	case !on:
+		return 0, nil
+
+	case days < 0:
+		return 0, fmt.Errorf("wash-sale window %d is negative", days)
+
+	case len(dates) == 0:
+		return 0, errors.New("the wash-sale mode needs the date layout")
+
+	case days == 0:
+		return 30, nil
+
+	default:
+		return days, nil
+	}
+}
+
+// number numbers the lots closed on the bar, going on from the lots closed
+// on the side earlier.
+func (this *Position) number() {
+	for i := range this.Closed {
+		this.ClosedN += 1
+		this.Closed[i].Seq = this.ClosedN
+	}
+}
+
+// washSales applies the wash-sale rule to the lots of both sides: the losses
+// on the lots closed within the window are matched share for share with the
+// other lots of the side held at the end of the bar which were opened within
+// the window, and the loss disallowed is added to the tax basis of the 
+// replacement lots (see Pending.WashAdj). Every share opened replaces a single
+// share sold. Losses not matched yet are carried over until the window is over.
+// Note: The rule is applied for the tax reports only; the cash, the basis and
+// the realized results are not affected. The holding periods of the lots sold
+// are not added to the holding periods of the replacement lots.
+func (this *Asset) washSales(prev Asset, q argsFIFO) {
+	this.S.washSales(prev.S, short, this.Bar, this.Date, q.Wash)
+	this.L.washSales(prev.L, long, this.Bar, this.Date, q.Wash)
+}
+
+// washSales applies the wash-sale rule to the lots of the side, see 
+// Asset.washSales.
+func (this *Position) washSales(prev Position, side, bar string, date time.Time, window int) {
+	this.Wash   = nil
+	this.Losses = nil
+
+	if window == 0 {
+		return
+	}
+
+	// Losses carried over, the expired ones dropped, and the new losses
+	var losses []washLoss
+	for _, one := range prev.Losses {
+		if !date.After(one.Sold.AddDate(0, 0, window)) {
+			losses = append(losses, one)
+		}
+	}
+	for _, one := range this.Closed {
+		// Note: Sign convention. SHORT ==> negative quantity. The loss is
+		// the tax loss, i.e. the losses washed into the lot sold included.
+		qty  := math.Abs(one.Qty)
+		loss := one.Rzd - one.WashAdj
+		if loss < 0 && qty > 0 {
+			losses = append(losses, washLoss{
+				Seq:      one.Seq,
+				EntryN:   one.EntryN,
+				EntryBar: one.EntryBar,
+				ExitBar:  one.ExitBar,
+				Sold:     one.Sold,
+				Qty:      qty,
+				Loss:     -loss / qty,
+			})
+		}
+	}
+	if len(losses) == 0 {
+		return
+	}
+
+	// Note: The queue is copied, so that the lots shared with the queue of
+	// the previous bar are left intact.
+	queue := make([]Pending, len(this.Queue))
+	copy(queue, this.Queue)
+
+	for i := range losses {
+		loss := &losses[i]
+		for k := range queue {
+			lot := &queue[k]
+			if loss.Qty <= 0 {
+				break
+			}
+			// Note: The rest of the lot sold is not a replacement.
+			if lot.N == loss.EntryN || !within(lot.Acquired, loss.Sold, window) {
+				continue
+			}
+			qty  := math.Abs(lot.Qty)
+			free := qty * (1 - lot.Washed)
+			if free < 1e-9 {
+				continue
+			}
+			n := math.Min(free, loss.Qty)
+			amount := n * loss.Loss
+
+			lot.WashAdj += amount
+			lot.Washed  += n / qty
+			loss.Qty    -= n
+
+			this.Wash = append(this.Wash, WashSale{
+				Side:        side,
+				Seq:         loss.Seq,
+				EntryBar:    loss.EntryBar,
+				ExitBar:     loss.ExitBar,
+				Replacement: lot.Bar,
+				Bar:         bar,
+				Qty:         n,
+				Disallowed:  amount,
+			})
+		}
+	}
+
+	for _, one := range losses {
+		if one.Qty >= 1e-9 {
+			this.Losses = append(this.Losses, one)
+		}
+	}
+	this.Queue = queue
+}
+
+// within tells whether the date is within the number of days either side of
+// the day.
+func within(date, day time.Time, days int) bool {
+	return !date.Before(day.AddDate(0, 0, -days)) && !date.After(day.AddDate(0, 0, days))
+}
+
+// rescaleLosses returns a copy of the losses not matched yet with quantities
+// multiplied and losses per share divided by the split ratio.
+func rescaleLosses(losses []washLoss, ratio float64) []washLoss {
+	scaled := make([]washLoss, len(losses))
+	for i, one := range losses {
+		one.Qty  *= ratio
+		one.Loss /= ratio
+		scaled[i] = one
+	}
+	return scaled
+}
+
+// washes collects the wash-sale adjustments in the order of the bars.
+func washes(allRecords []Asset) []WashSale {
+	var all []WashSale
+	for _, one := range allRecords {
+		all = append(all, one.S.Wash...)
+		all = append(all, one.L.Wash...)
+	}
+	return all
+}
+
+// washLedger puts the losses disallowed into the ledger entries of the lots
+// closed at a loss.
+func washLedger(closed []Closed, adjustments []WashSale) {
+	index := make(map[string]int, len(closed))
+	for i, one := range closed {
+		index[fmt.Sprintf("%s|%s|%d", one.Symbol, one.Side, one.Seq)] = i
+	}
+	for _, one := range adjustments {
+		if i, ok := index[fmt.Sprintf("%s|%s|%d", one.Symbol, one.Side, one.Seq)]; ok {
+			closed[i].Wash += one.Disallowed
+		}
+	}
+}
+
+// writeCSVwash exports the wash-sale adjustments in the CSV format. Symbols
+// are put into the first column in the portfolio mode.
+func writeCSVwash(adjustments []WashSale, symbols bool, outFile string) {
+	var (
+		field []string
+	)
+
+	csvNewFile, err := createCSV(outFile)
+	if err != nil {
+		fmt.Println("Output file creating error:", err)
+		return
+	}
+	defer csvNewFile.Close()
+
+	writer := csv.NewWriter(csvNewFile)
+
+	headers := strings.Split(washAttributes, "\n")
+	if symbols {
+		writer.Write(append([]string{"Symbol"}, headers...))
+	} else {
+		writer.Write(headers)
+	}
+
+	for _, one := range adjustments {
+		field = make([]string, len(headers))
+
+		field[0] = one.Side
+		field[1] = one.EntryBar
+		field[2] = one.ExitBar
+		field[3] = one.Replacement
+		field[4] = one.Bar
+		field[5] = fmt.Sprintf("%f", one.Qty)
+		field[6] = fmt.Sprintf("%f", one.Disallowed)
+
+		if symbols {
+			field = append([]string{one.Symbol}, field...)
+		}
+		writer.Write(field)
+	}
+	writer.Flush()
+}
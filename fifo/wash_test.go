@@ -0,0 +1,169 @@
+// Copyright (c) 2020 Sergey Dugaev. All rights reserved.
+// Licensed under the MIT license.
+// See the LICENSE file in the project root for more information.
+
+package fifo
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// dated returns the bars of the dates, the prices and the sizes of position.
+func dated(dates []string, prices, positions []float64) []Bar {
+	bars := make([]Bar, len(dates))
+	for i := range dates {
+		bars[i] = Bar{ID: dates[i], Close: prices[i], Trade: prices[i], Position: positions[i]}
+	}
+	return bars
+}
+
+func TestWashSales(t *testing.T) {
+	tests := []struct {
+		name       string
+		bars       []Bar
+		washes     int
+		disallowed float64
+		adjusted   float64 // the wash-sale adjustment of the replacement lot
+		cost       float64 // the cost basis of the lot closed last
+	}{
+		{
+			name: "long lot replaced after the loss",
+			bars: dated([]string{"2020-01-02", "2020-01-10", "2020-01-20", "2020-03-02"},
+				[]float64{100, 90, 95, 110}, []float64{1, 0, 1, 0}),
+			washes: 1, disallowed: 1000, adjusted: 1000, cost: 11000,
+		},
+		{
+			name: "long lot replaced before the loss",
+			bars: dated([]string{"2020-01-02", "2020-01-06", "2020-01-10", "2020-03-02"},
+				[]float64{100, 80, 90, 110}, []float64{1, 2, 1, 0}),
+			washes: 1, disallowed: 1000, adjusted: 1000, cost: 11000,
+		},
+		{
+			name: "long lot replaced after the window",
+			bars: dated([]string{"2020-01-02", "2020-01-10", "2020-02-15", "2020-03-02"},
+				[]float64{100, 90, 95, 110}, []float64{1, 0, 1, 0}),
+			washes: 0, disallowed: 0, adjusted: 0, cost: 10000,
+		},
+		{
+			name: "long lot closed at a gain",
+			bars: dated([]string{"2020-01-02", "2020-01-10", "2020-01-20", "2020-03-02"},
+				[]float64{100, 110, 95, 110}, []float64{1, 0, 1, 0}),
+			washes: 0, disallowed: 0, adjusted: 0, cost: 10000,
+		},
+		{
+			name: "short lot replaced after the loss",
+			bars: dated([]string{"2020-01-02", "2020-01-10", "2020-01-15", "2020-03-02"},
+				[]float64{100, 110, 100, 90}, []float64{-1, 0, -1, 0}),
+			washes: 1, disallowed: 1000, adjusted: 1000, cost: 10000,
+		},
+		{
+			name: "loss washed by a smaller replacement in part",
+			bars: dated([]string{"2020-01-02", "2020-01-10", "2020-01-20", "2020-03-02"},
+				[]float64{100, 90, 200, 210}, []float64{1, 0, 1, 0}),
+			washes: 1, disallowed: 500, adjusted: 500, cost: 10500,
+		},
+		{
+			name: "replacement sold at break-even and bought back",
+			bars: dated([]string{"2020-01-02", "2020-01-10", "2020-01-15", "2020-01-20", "2020-01-25", "2020-03-30"},
+				[]float64{100, 90, 90, 90, 90, 100}, []float64{1, 0, 1, 0, 1, 0}),
+			washes: 2, disallowed: 2000, adjusted: 2000, cost: 11000,
+		},
+	}
+	for _, tt := range tests {
+		par := Params{Cash: 1e6, Lim: 10000, DateLayout: "2006-01-02", WashSale: true}
+
+		res, err := Simulate(context.Background(), tt.bars, par)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		var disallowed float64
+		for _, one := range res.Wash {
+			disallowed += one.Disallowed
+		}
+		if len(res.Wash) != tt.washes || math.Abs(disallowed - tt.disallowed) > 1e-6 {
+			t.Errorf("%s: %d adjustments of %v, want %d of %v", tt.name, len(res.Wash), disallowed, tt.washes, tt.disallowed)
+		}
+
+		// The ledger sums up to the realized results, the adjustments being
+		// made for the tax reports only
+		var realized, ledger, wash, adjusted, gainLoss float64
+		for _, one := range res.Assets {
+			realized += one.S.Result.Rzd + one.L.Result.Rzd
+		}
+		for _, one := range res.Ledger {
+			ledger   += one.Rzd
+			wash     += one.Wash
+			adjusted += one.WashAdj
+			gainLoss += one.GainLoss()
+		}
+		if last := res.Ledger[len(res.Ledger) - 1]; math.Abs(last.CostBasis() - tt.cost) > 1e-6 {
+			t.Errorf("%s: cost basis %v of the lot closed last, want %v", tt.name, last.CostBasis(), tt.cost)
+		}
+		if math.Abs(ledger - realized) > 1e-6 {
+			t.Errorf("%s: ledger sums up to %v, want the realized results %v", tt.name, ledger, realized)
+		}
+		if math.Abs(wash - tt.disallowed) > 1e-6 || math.Abs(adjusted - tt.adjusted) > 1e-6 {
+			t.Errorf("%s: %v disallowed and %v adjusted in the ledger, want %v and %v", tt.name, wash, adjusted, tt.disallowed, tt.adjusted)
+		}
+		// All the lots are closed, so the adjustments cancel out
+		if math.Abs(gainLoss - realized) > 1e-6 {
+			t.Errorf("%s: gains and losses sum up to %v, want %v", tt.name, gainLoss, realized)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		date string
+		want bool
+	}{
+		{"2020-03-01", true},
+		{"2020-01-31", true},
+		{"2020-01-30", false},
+		{"2020-03-31", true},
+		{"2020-04-01", false},
+	}
+	for _, tt := range tests {
+		if got := within(day(tt.date), day("2020-03-01"), 30); got != tt.want {
+			t.Errorf("within(%s) = %v, want %v", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestRescaleLosses(t *testing.T) {
+	losses := []washLoss{{Seq: 1, Qty: 100, Loss: 10}, {Seq: 2, Qty: 30, Loss: 6}}
+
+	tests := []struct {
+		ratio float64
+		qty   []float64
+		loss  []float64
+	}{
+		{2, []float64{200, 60}, []float64{5, 3}},
+		{1.25, []float64{125, 37.5}, []float64{8, 4.8}},
+		{1.0 / 3, []float64{100.0 / 3, 10}, []float64{30, 18}},
+	}
+	for _, tt := range tests {
+		scaled := rescaleLosses(losses, tt.ratio)
+		for i, one := range scaled {
+			if math.Abs(one.Qty - tt.qty[i]) > 1e-9 || math.Abs(one.Loss - tt.loss[i]) > 1e-9 {
+				t.Errorf("ratio %v: loss %d of %v shares at %v, want %v at %v", tt.ratio, i, one.Qty, one.Loss, tt.qty[i], tt.loss[i])
+			}
+			// The loss of the lot is the same
+			if math.Abs(one.Qty * one.Loss - losses[i].Qty * losses[i].Loss) > 1e-9 {
+				t.Errorf("ratio %v: loss %d changed to %v", tt.ratio, i, one.Qty * one.Loss)
+			}
+		}
+	}
+	// The losses carried over from the previous bar are left intact
+	if losses[0].Qty != 100 || losses[0].Loss != 10 {
+		t.Errorf("losses changed to %v", losses)
+	}
+}
@@ -22,21 +22,50 @@ func main() {
 	switch {
 	case len(config.Signals) == 0:
 		// Do nothing
-		fmt.Println("No trade signals found! Calculation aborted.\n")
+		fmt.Println("No trade signals found! Calculation aborted.")
+
+	case len(config.Actions) > 0 && len(config.Actions) != len(config.Signals):
+		// Do nothing
+		fmt.Println("Check the config! The numbers of input and corporate actions files must be the same.")
+
+	case len(config.Ledgers) > 0 && len(config.Ledgers) != len(config.Results):
+		// Do nothing
+		fmt.Println("Check the config! The numbers of output and ledger files must be the same.")
+
+	case len(config.Summaries) > 0 && len(config.Summaries) != len(config.Results):
+		// Do nothing
+		fmt.Println("Check the config! The numbers of output and summary files must be the same.")
+
+	case len(config.TaxLots) > 0 && len(config.TaxLots) != len(config.Results):
+		// Do nothing
+		fmt.Println("Check the config! The numbers of output and tax lot files must be the same.")
+
+	case len(config.TaxReports) > 0 && len(config.TaxReports) != len(config.Results):
+		// Do nothing
+		fmt.Println("Check the config! The numbers of output and tax report files must be the same.")
+
+	case len(config.WashReports) > 0 && len(config.WashReports) != len(config.Results):
+		// Do nothing
+		fmt.Println("Check the config! The numbers of output and wash-sale report files must be the same.")
+
+	case (len(config.TaxLots) > 0 || len(config.TaxReports) > 0) && len(config.DateLayout) == 0:
+		// Do nothing
+		fmt.Println("Check the config! A date layout is needed for the tax reports.")
+
+	case config.Portfolio && len(config.Results) != 1:
+		// Do nothing
+		fmt.Println("Check the config! A single output file is expected in the portfolio mode.")
+
+	case config.Portfolio:
+		// Note: all inputs make up a single portfolio.
+		fifo.ModelPortfolio(config.PortfolioFiles(), config.Params())
 
 	case len(config.Signals) == len(config.Results):
 		// Note: the same parameters for all inputs.
-		params := fifo.Params{
-			Cash:    config.Cash,
-			Lim:     config.Lim,
-			Fee:     config.Fee,
-			Headers: config.Headers,
-		}
+		params := config.Params()
 
 		for i := range config.Signals {
-			fifo.Model(config.Home + config.Signals[i], 
-				config.Home + config.Results[i], 
-				params)
+			fifo.Model(config.Files(i), params)
 		}
 
 	default: